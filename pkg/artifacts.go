@@ -0,0 +1,188 @@
+package frame2
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The artifacts are files kept for the triage of a test run, under
+// ENV_ARTIFACT_DIR.  The root test and each named phase or step (ie, each
+// *testing.T) get their own directory, nested as their test names are,
+// holding:
+//
+//   - output.log, with the log lines of the runners on that subtree
+//   - summary.txt, written when the test finishes, with its result and
+//     the report tree of the subtree (see Run.ReportTree)
+//   - any files written with Run.WriteArtifact, such as the outputs of the
+//     commands run by f2general.Cmd
+//
+// As each directory gets the logs of its whole subtree, the lines of a
+// step can be found on its own output.log and on those of all tests above
+// it
+var artifactLock sync.Mutex
+
+// Characters that are replaced on the directory names
+var artifactUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Returns the directory for the artifacts of the runner: that of the
+// nearest runner above it (or itself) that corresponds to a test.  It
+// returns an empty string if ENV_ARTIFACT_DIR is not set
+func (r *Run) ArtifactDir() string {
+	artifactLock.Lock()
+	defer artifactLock.Unlock()
+	for ; r != nil; r = r.parent {
+		if r.artifactDir != "" {
+			return r.artifactDir
+		}
+	}
+	return ""
+}
+
+// Writes data to a file on the runner's artifact directory (see
+// ArtifactDir), and returns its path.  If the file exists, a number is
+// added to the name, so that repeated executions (such as retries) do not
+// overwrite each other's.
+//
+// If there is no artifact directory, nothing is done, and the path is
+// empty
+func (r *Run) WriteArtifact(name string, data []byte) (string, error) {
+	dir := r.ArtifactDir()
+	if dir == "" {
+		return "", nil
+	}
+	name = artifactUnsafe.ReplaceAllString(name, "_")
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	artifactLock.Lock()
+	defer artifactLock.Unlock()
+	path := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create artifact: %w", err)
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write artifact %q: %w", path, err)
+		}
+		return path, nil
+	}
+}
+
+// Creates the artifact directory for the runner's test, if ENV_ARTIFACT_DIR
+// is set.  It is called for the root runner and for those of named phases
+// and steps.  The summary is written, and the log closed, on the test's
+// cleanup
+func (r *Run) openArtifacts() {
+	root := settingArtifactDir.Value()
+	if root == "" || r.T == nil {
+		return
+	}
+	artifactLock.Lock()
+	defer artifactLock.Unlock()
+	if r.artifactDir != "" {
+		return
+	}
+
+	segments := []string{root}
+	for _, s := range strings.Split(r.T.Name(), "/") {
+		s = artifactUnsafe.ReplaceAllString(s, "_")
+		if strings.Trim(s, ".") == "" {
+			s = "_" + s
+		}
+		segments = append(segments, s)
+	}
+	dir := filepath.Join(segments...)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("[R] %v failed to create the artifact directory: %v", r.GetId(), err)
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "output.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("[R] %v failed to create the artifact log: %v", r.GetId(), err)
+		return
+	}
+	r.artifactDir = dir
+	r.artifactLog = f
+	start := time.Now()
+	t := r.T
+	t.Cleanup(func() {
+		artifactLock.Lock()
+		r.artifactLog = nil
+		artifactLock.Unlock()
+		if err := f.Close(); err != nil {
+			log.Printf("[R] %v failed to close the artifact log: %v", r.GetId(), err)
+		}
+		status := "PASS"
+		switch {
+		case t.Skipped():
+			status = "SKIP"
+		case t.Failed():
+			status = "FAIL"
+		}
+		if err := r.writeArtifactSummary(status, time.Since(start)); err != nil {
+			log.Printf("[R] %v failed to write the artifact summary: %v", r.GetId(), err)
+		}
+	})
+}
+
+// Appends the line to the output.log of the runner's test and of all
+// tests above it
+func (r *Run) writeArtifactLog(line string) {
+	line = time.Now().Format("2006/01/02 15:04:05 ") + strings.TrimSuffix(line, "\n") + "\n"
+	artifactLock.Lock()
+	defer artifactLock.Unlock()
+	for ; r != nil; r = r.parent {
+		if r.artifactLog != nil {
+			_, _ = io.WriteString(r.artifactLog, line)
+		}
+	}
+}
+
+func (r *Run) writeArtifactSummary(status string, duration time.Duration) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Test:     %s\n", r.T.Name())
+	fmt.Fprintf(&b, "Runner:   %s\n", r.GetId())
+	if r.parent == nil && r.Doc != "" {
+		fmt.Fprintf(&b, "Doc:      %s\n", r.Doc)
+	}
+	fmt.Fprintf(&b, "Result:   %s\n", status)
+	fmt.Fprintf(&b, "Duration: %v\n", duration.Round(time.Millisecond))
+	if node := r.reportNode(); node != nil {
+		fmt.Fprintf(&b, "Steps:    %v\n", node.StepCounts())
+		b.WriteString("\n")
+		writeSummaryNode(&b, node, 0)
+	}
+	return os.WriteFile(filepath.Join(r.ArtifactDir(), "summary.txt"), []byte(b.String()), 0644)
+}
+
+func writeSummaryNode(w io.Writer, node *ReportNode, depth int) {
+	result := "ok"
+	switch {
+	case node.Failed():
+		result = "FAILED"
+	case node.Skipped():
+		result = "skipped"
+	}
+	fmt.Fprintf(w, "%s%s %s (%v) %s\n", strings.Repeat("  ", depth), node.Id, result, node.Duration.Round(time.Millisecond), node.Title())
+	if node.Err != nil {
+		fmt.Fprintf(w, "%s  error: %v\n", strings.Repeat("  ", depth), node.Err)
+	}
+	for _, c := range node.Children {
+		writeSummaryNode(w, c, depth+1)
+	}
+}
@@ -0,0 +1,67 @@
+package frame2_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"gotest.tools/assert"
+)
+
+func TestArtifacts(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(frame2.ENV_ARTIFACT_DIR, root)
+
+	validator := &f2general.Function{}
+	validator.Fn = func() error {
+		validator.Printf("said hi")
+		return nil
+	}
+
+	t.Run("inner", func(t *testing.T) {
+		p := frame2.Phase{
+			Runner: &frame2.Run{T: t, Doc: "Artifacts test"},
+			MainSteps: []frame2.Step{
+				{
+					Name: "say hi",
+					Doc:  "Says hi",
+					Modify: &f2general.Cmd{
+						Command: "echo",
+						Cmd:     exec.Cmd{Args: []string{"hi"}},
+					},
+					Validator: validator,
+				},
+			},
+		}
+		assert.Assert(t, p.Run())
+	})
+
+	testDir := filepath.Join(root, "TestArtifacts", "inner")
+	stepDir := filepath.Join(testDir, "say_hi")
+
+	summary, err := os.ReadFile(filepath.Join(testDir, "summary.txt"))
+	assert.Assert(t, err)
+	for _, expected := range []string{"Test:     TestArtifacts/inner\n", "Doc:      Artifacts test\n", "Result:   PASS\n", "SubT0 ok"} {
+		assert.Assert(t, strings.Contains(string(summary), expected), "%q not on:\n%s", expected, summary)
+	}
+
+	stepSummary, err := os.ReadFile(filepath.Join(stepDir, "summary.txt"))
+	assert.Assert(t, err)
+	assert.Assert(t, strings.Contains(string(stepSummary), "Runner:   SubT0\n"), string(stepSummary))
+
+	// The step's lines are on its log, and on the one of the test above it
+	for _, dir := range []string{testDir, stepDir} {
+		output, err := os.ReadFile(filepath.Join(dir, "output.log"))
+		assert.Assert(t, err)
+		assert.Assert(t, strings.Contains(string(output), `doc "Says hi"`), string(output))
+		assert.Assert(t, strings.Contains(string(output), "[INFO] SubT0 said hi"), string(output))
+	}
+
+	stdout, err := os.ReadFile(filepath.Join(stepDir, "cmd-SubT0.m0.stdout"))
+	assert.Assert(t, err)
+	assert.Equal(t, string(stdout), "hi\n")
+}
@@ -0,0 +1,234 @@
+package frame2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+// Contexts created by the framework itself are wrapped on this type, so
+// they can be told apart from those given by the user.  A frame whose Ctx
+// was set by the framework on a previous run gets it replaced, while a
+// user's context is never touched
+type frameworkContext struct {
+	context.Context
+}
+
+// Whether ctx was created by the framework (for example, the root context
+// injected on frames that did not set their own Ctx).
+//
+// Frames that apply a default timeout when no context is given should
+// still apply it for framework contexts, as those are only bound by the
+// test deadline
+func IsFrameworkContext(ctx context.Context) bool {
+	_, ok := ctx.(frameworkContext)
+	return ok
+}
+
+// Creates the context for a root Run.  If the test has a deadline, the
+// context is set to expire before it: one minute before, or 10% of the
+// remaining time, if less than 10 minutes remain.  That margin is left for
+// the teardowns, which are not bound by this context
+func (r *Run) newRootContext() (context.Context, context.CancelFunc) {
+	t := r.savedT
+	if t == nil {
+		t = r.T
+	}
+	if t != nil {
+		if deadline, ok := t.Deadline(); ok {
+			remaining := time.Until(deadline)
+			margin := time.Minute
+			if remaining < 10*time.Minute {
+				margin = remaining / 10
+			}
+			ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-margin))
+			r.logf(LevelDebug, "[R] %v context deadline set to %v (%v before the test deadline)", r.GetId(), deadline.Add(-margin), margin)
+			return frameworkContext{ctx}, cancel
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return frameworkContext{ctx}, cancel
+}
+
+// The context for teardowns keeps the values of ctx, but not its deadline
+// or cancellation; the time left by the root context deadline is reserved
+// for them
+func teardownContext(ctx context.Context) context.Context {
+	return frameworkContext{context.WithoutCancel(ctx)}
+}
+
+// Returns a copy of the options, with the runner's context if the options
+// did not have any
+func (r *Run) retryOptions(options RetryOptions) RetryOptions {
+	if options.Ctx == nil {
+		options.Ctx = r.GetContext()
+	}
+	return options
+}
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+var defaultRunDealerType = reflect.TypeOf(DefaultRunDealer{})
+
+// Returns the Ctx fields of the struct v (its own, and that of an embedded
+// DefaultRunDealer) that can receive a framework context: those that are nil
+// or already have a framework context
+func contextFields(v reflect.Value) []reflect.Value {
+	var candidates []reflect.Value
+	if f, ok := v.Type().FieldByName("Ctx"); ok {
+		if field, err := v.FieldByIndexErr(f.Index); err == nil {
+			candidates = append(candidates, field)
+		}
+	}
+	if f, ok := v.Type().FieldByName("DefaultRunDealer"); ok && f.Type == defaultRunDealerType {
+		if field, err := v.FieldByIndexErr(f.Index); err == nil {
+			candidates = append(candidates, field.FieldByName("Ctx"))
+		}
+	}
+
+	var ret []reflect.Value
+	for _, field := range candidates {
+		if field.Type() != contextType || !field.CanSet() {
+			continue
+		}
+		if field.IsNil() || IsFrameworkContext(field.Interface().(context.Context)) {
+			ret = append(ret, field)
+		}
+	}
+	return ret
+}
+
+// Returns a TransformFunc (see Step.IterFrames) that sets ctx on the Ctx
+// fields of the frames that do not have a context of their own.  Frames
+// given as pointers are changed in place; for frames given as values, a
+// changed copy is returned
+func injectContext(ctx context.Context) TransformFunc {
+	return func(frame any) (any, error) {
+		v := reflect.ValueOf(frame)
+		switch {
+		case v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().Kind() == reflect.Struct:
+			for _, field := range contextFields(v.Elem()) {
+				field.Set(reflect.ValueOf(ctx))
+			}
+			return frame, nil
+		case v.Kind() == reflect.Struct:
+			// Values are not addressable, so we work on a copy
+			frameCopy := reflect.New(v.Type()).Elem()
+			frameCopy.Set(v)
+			fields := contextFields(frameCopy)
+			if len(fields) == 0 {
+				return frame, nil
+			}
+			for _, field := range fields {
+				field.Set(reflect.ValueOf(ctx))
+			}
+			return frameCopy.Interface(), nil
+		}
+		return frame, nil
+	}
+}
+
+// The cause of the contexts created for Step.Timeout and Phase.Timeout,
+// and the error returned when they expire.  It matches
+// context.DeadlineExceeded on errors.Is
+type TimeoutError struct {
+	Id      string // of the step's or phase's runner
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%v timed out after %v", e.Id, e.Timeout)
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// Returns the cause of the runner's context if it is done, or nil.
+//
+// The phase and step loops check it before each step, substep, validator
+// and retry attempt, so that a phase or step abandoned by runWithTimeout
+// stops at the next one, instead of running the rest of its steps after
+// the timeout was reported
+func (r *Run) contextDone() error {
+	ctx := r.GetContext()
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
+
+// How long runWithTimeout waits for frames to return after their context
+// is done, before abandoning them, if Run.TimeoutGrace is not set
+const defaultTimeoutGrace = time.Second
+
+func (r *Run) timeoutGrace() time.Duration {
+	if grace := r.getRoot().TimeoutGrace; grace > 0 {
+		return grace
+	}
+	return defaultTimeoutGrace
+}
+
+// Runs fn with a context on the runner (see GetContext) that expires after
+// the timeout.  fn runs on its own goroutine, so that frames that ignore
+// the context do not hang the test: if it does not return within
+// Run.TimeoutGrace after the context is done, fn is abandoned, and the
+// context's cause is returned.  If fn returns an error
+// after the timeout, that error is wrapped by the TimeoutError.
+//
+// When fn returns, the runner gets its previous context back.  Abandoned
+// runners keep the expired one, so that the steps still running on them
+// stop at the next step (see contextDone); they must not be reused.
+//
+// Abandoned goroutines keep running until their frames return; if they
+// log to the test's *testing.T after it finishes, the test panics.  If fn
+// calls runtime.Goexit (as t.FailNow does), so does runWithTimeout, on the
+// caller's goroutine
+func (r *Run) runWithTimeout(timeout time.Duration, fn func() error) error {
+	timeoutErr := &TimeoutError{Id: r.GetId(), Timeout: timeout}
+	ctx, cancel := context.WithTimeoutCause(r.GetContext(), timeout, timeoutErr)
+	defer cancel()
+	runnerLock.Lock()
+	previousCtx, previousCancel := r.ctx, r.cancelCtx
+	r.ctx, r.cancelCtx = frameworkContext{ctx}, cancel
+	runnerLock.Unlock()
+	abandoned := false
+	defer func() {
+		if abandoned {
+			return
+		}
+		runnerLock.Lock()
+		r.ctx, r.cancelCtx = previousCtx, previousCancel
+		runnerLock.Unlock()
+	}()
+
+	done := make(chan struct{})
+	var err error
+	var returned bool
+	go func() {
+		defer close(done)
+		err = fn()
+		returned = true
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		select {
+		case <-done:
+		case <-time.After(r.timeoutGrace()):
+			abandoned = true
+			r.logf(LevelWarn, "[R] %v abandoned, still running: %v", r.GetId(), context.Cause(ctx))
+			return context.Cause(ctx)
+		}
+	}
+	if !returned {
+		runtime.Goexit()
+	}
+	if err != nil && context.Cause(ctx) == timeoutErr && !errors.Is(err, timeoutErr) {
+		err = fmt.Errorf("%w: %w", timeoutErr, err)
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package frame2_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"gotest.tools/assert"
+)
+
+type ctxKey struct{}
+
+// Saves the context it got on Ctx, when executed
+type ctxRecorder struct {
+	Ctx   context.Context
+	saved *context.Context
+}
+
+func (c ctxRecorder) Execute() error {
+	*c.saved = c.Ctx
+	return nil
+}
+
+func TestRootContext(t *testing.T) {
+	var injected, user, valueFrame, teardown context.Context
+	userCtx := context.WithValue(context.Background(), ctxKey{}, "user")
+
+	valueStep := frame2.Step{
+		Modify: ctxRecorder{saved: &valueFrame},
+	}
+	p := frame2.Phase{
+		Setup: []frame2.Step{
+			{
+				Modify: &ctxRecorder{saved: &injected},
+			}, {
+				Modify: &ctxRecorder{Ctx: userCtx, saved: &user},
+			},
+		},
+		MainSteps: []frame2.Step{
+			valueStep,
+		},
+		Teardown: []frame2.Step{
+			{
+				Modify: &ctxRecorder{saved: &teardown},
+			},
+		},
+	}
+	// The teardown runs on the cleanup of the runner's test, so it needs
+	// its own
+	t.Run("phase", func(t *testing.T) {
+		p.Runner = &frame2.Run{T: t}
+		assert.Assert(t, p.Run())
+	})
+
+	assert.Assert(t, frame2.IsFrameworkContext(injected))
+	if deadline, ok := t.Deadline(); ok {
+		ctxDeadline, ok := injected.Deadline()
+		assert.Assert(t, ok, "the root context should have a deadline")
+		assert.Assert(t, ctxDeadline.Before(deadline))
+		assert.Assert(t, ctxDeadline.Add(time.Minute+time.Second).After(deadline), "the margin should be at most one minute")
+	}
+
+	// The user's context is kept
+	assert.Equal(t, user, userCtx)
+
+	// Frames given as values get the context on a copy
+	assert.Assert(t, frame2.IsFrameworkContext(valueFrame))
+	assert.Assert(t, valueStep.Modify.(ctxRecorder).Ctx == nil)
+
+	// Teardowns are not bound by the root context deadline
+	assert.Assert(t, frame2.IsFrameworkContext(teardown))
+	_, ok := teardown.Deadline()
+	assert.Assert(t, !ok, "teardown contexts should have no deadline")
+}
+
+// Waits for its context to be done, and returns its error
+type ctxWaiter struct {
+	frame2.DefaultRunDealer
+	deadline *bool
+}
+
+func (w *ctxWaiter) Execute() error {
+	_, *w.deadline = w.Ctx.Deadline()
+	<-w.Ctx.Done()
+	return w.Ctx.Err()
+}
+
+func TestTimeout(t *testing.T) {
+	// Closed at the end, to release the abandoned frames
+	block := make(chan struct{})
+	defer close(block)
+	blocker := &frame2.Procedure{
+		Fn: func() { <-block },
+	}
+
+	var ran, hadDeadline bool
+	var timeoutErr *frame2.TimeoutError
+
+	// A frame that ignores its context is abandoned, after the grace
+	p := frame2.Phase{
+		Runner: &frame2.Run{TimeoutGrace: 10 * time.Millisecond},
+		MainSteps: []frame2.Step{
+			{
+				Modify:  blocker,
+				Timeout: 50 * time.Millisecond,
+			}, {
+				Modify: &frame2.Procedure{
+					Fn: func() { ran = true },
+				},
+			},
+		},
+	}
+	err := p.Run()
+	assert.Assert(t, errors.As(err, &timeoutErr), err)
+	assert.Equal(t, timeoutErr.Id, "R0.p0.s0")
+	assert.Equal(t, timeoutErr.Timeout, 50*time.Millisecond)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	assert.ErrorContains(t, err, "R0.p0.s0 timed out after 50ms")
+	assert.Assert(t, !ran, "the steps after a timeout should not run")
+
+	// A RunDealer frame gets the timeout on its Ctx, and its own error is
+	// kept
+	p = frame2.Phase{
+		MainSteps: []frame2.Step{
+			{
+				Modify:  &ctxWaiter{deadline: &hadDeadline},
+				Timeout: 50 * time.Millisecond,
+			},
+		},
+	}
+	err = p.Run()
+	assert.Assert(t, hadDeadline)
+	assert.Assert(t, errors.As(err, &timeoutErr), err)
+	assert.ErrorContains(t, err, "timed out after 50ms: modify step failed: context deadline exceeded")
+
+	// The phase timeout applies to all its steps together
+	p = frame2.Phase{
+		Timeout: 50 * time.Millisecond,
+		MainSteps: []frame2.Step{
+			{
+				Modify: &frame2.Procedure{Fn: func() { time.Sleep(30 * time.Millisecond) }},
+			}, {
+				Modify: blocker,
+			},
+		},
+	}
+	err = p.Run()
+	assert.Assert(t, errors.As(err, &timeoutErr), err)
+	assert.Equal(t, timeoutErr.Id, "R0")
+
+	// Steps that finish in time are not affected
+	p = frame2.Phase{
+		Timeout: time.Minute,
+		MainSteps: []frame2.Step{
+			{
+				Modify:  &frame2.Procedure{Fn: func() {}},
+				Timeout: time.Minute,
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+	// and the runner gets its own context back, for later use
+	assert.Assert(t, p.GetRunner().GetContext().Err())
+}
+
+// An abandoned phase stops at its next step, validator or substep, instead
+// of running the rest of them after its timeout was reported
+func TestTimeoutStops(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	var validated, substep, later atomic.Bool
+
+	p := frame2.Phase{
+		Timeout: 50 * time.Millisecond,
+		MainSteps: []frame2.Step{
+			{
+				Modify: &frame2.Procedure{Fn: func() { <-release }},
+			}, {
+				Modify: &frame2.Procedure{Fn: func() { later.Store(true) }},
+				Validator: &f2general.Function{
+					Fn: func() error { validated.Store(true); return nil },
+				},
+				Substeps: []*frame2.Step{
+					{
+						Modify: &frame2.Procedure{Fn: func() { substep.Store(true) }},
+					},
+				},
+			},
+		},
+		// Not bound by the timeout, so it tells when the abandoned phase
+		// is done
+		Teardown: []frame2.Step{
+			{
+				Modify: &frame2.Procedure{Fn: func() { close(finished) }},
+			},
+		},
+	}
+	err := p.Run()
+	var timeoutErr *frame2.TimeoutError
+	assert.Assert(t, errors.As(err, &timeoutErr), err)
+
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the abandoned phase did not finish")
+	}
+	assert.Assert(t, !substep.Load(), "substeps should not run after the timeout")
+	assert.Assert(t, !validated.Load(), "validators should not run after the timeout")
+	assert.Assert(t, !later.Load(), "main steps should not run after the timeout")
+}
@@ -1,5 +1,35 @@
 package frame2
 
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The environment variable that selects the disruptors for a test run.  It
+// is a list of disruptor names (their DisruptorEnvValue) separated by
+// semicolons; each may be followed by a colon and its configuration.
+//
+// Any disruptor can be limited to part of the test with scope keys on its
+// configuration, separated by commas from each other and from the
+// disruptor's own configuration:
+//
+//   - scope-id=GLOB and scope-id-re=REGEX match the step's runner ID
+//     (such as SubT0.m0.p0.s1)
+//   - scope-name=GLOB and scope-name-re=REGEX match the step's Name
+//   - scope-kind=validator or scope-kind=modify limit the disruptor to
+//     the step's validators or to its Modify.  Disruptors that cannot
+//     act on that part of the step (such as those with a
+//     ValidationResultHook, for scope-kind=modify) are refused
+//
+// For example, "MIN_ALLOWS:5,scope-id=SubT2.*,scope-kind=validator".  All
+// given keys need to match.  Scopes apply to the step inspection and to the
+// validation result hook; the other hooks are not step-specific
+const ENV_DISRUPTOR = "SKUPPER_TEST_DISRUPTOR"
+
 // TODO Add a new method, Exercised() bool, that reports
 // whether the disruptor was exercised at all, and causes
 // the test to report Skipped if not.
@@ -18,9 +48,10 @@ type DisruptorConfigurer interface {
 }
 
 // This is just a marker to indicate that the disruptor does
-// not need to be listed on Run.AlwaysDisruptor on the test;
+// not need to be listed on Run.AllowDisruptors on the test;
 // just having it on the environment will suffice for it to
-// take effect
+// take effect.  For that, it needs to be registered with
+// RegisterDisruptor
 type AlwaysDisruptor interface {
 	// This is just a marker; it does nothing
 	AlwaysDisruptor()
@@ -68,3 +99,271 @@ type FinalizerHook interface {
 type ValidationResultHook interface {
 	ValidationResultHook(runner *Run, step Step, err error) error
 }
+
+// Returns a new instance of a disruptor.  Disruptors with pointer receiver
+// methods should return a pointer, so those methods are on its method set
+type DisruptorFactory func() Disruptor
+
+var (
+	disruptorRegistry     = map[string]DisruptorFactory{}
+	disruptorRegistryLock sync.Mutex
+)
+
+// Adds a disruptor to the package-level registry, under its
+// DisruptorEnvValue.  Disruptor packages call this from their init().
+//
+// Registered disruptors that implement AlwaysDisruptor take effect on any
+// test, if requested on ENV_DISRUPTOR.  Registering the same name twice
+// panics
+func RegisterDisruptor(factory DisruptorFactory) {
+	name := factory().DisruptorEnvValue()
+	disruptorRegistryLock.Lock()
+	defer disruptorRegistryLock.Unlock()
+	if _, ok := disruptorRegistry[name]; ok {
+		panic(fmt.Sprintf("disruptor %q registered twice", name))
+	}
+	disruptorRegistry[name] = factory
+}
+
+// Returns a new instance of the registered disruptor with the given name,
+// or nil if there is none
+func newRegisteredDisruptor(name string) Disruptor {
+	disruptorRegistryLock.Lock()
+	factory, ok := disruptorRegistry[name]
+	disruptorRegistryLock.Unlock()
+	if !ok {
+		return nil
+	}
+	return factory()
+}
+
+// Describes a registered disruptor; see ListDisruptors
+type DisruptorInfo struct {
+	Name         string // As given on ENV_DISRUPTOR
+	Type         string
+	Configurable bool // Whether it accepts a configuration (implements DisruptorConfigurer)
+	Always       bool // Whether it applies to any test (implements AlwaysDisruptor)
+}
+
+// Lists all registered disruptors, sorted by name.  Only the disruptors
+// from packages that were imported by the test binary are registered
+func ListDisruptors() []DisruptorInfo {
+	disruptorRegistryLock.Lock()
+	defer disruptorRegistryLock.Unlock()
+	ret := []DisruptorInfo{}
+	for name, factory := range disruptorRegistry {
+		d := factory()
+		_, configurable := d.(DisruptorConfigurer)
+		_, always := d.(AlwaysDisruptor)
+		ret = append(ret, DisruptorInfo{
+			Name:         name,
+			Type:         fmt.Sprintf("%T", d),
+			Configurable: configurable,
+			Always:       always,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// A disruptor requested on ENV_DISRUPTOR, with its configuration (if any)
+type disruptorRequest struct {
+	name string
+	conf string
+}
+
+func requestedDisruptors() []disruptorRequest {
+	value := settingDisruptor.Value()
+	if value == "" {
+		return nil
+	}
+	ret := []disruptorRequest{}
+	for _, d := range strings.Split(value, ";") {
+		name, conf, _ := strings.Cut(d, ":")
+		ret = append(ret, disruptorRequest{name: name, conf: conf})
+	}
+	return ret
+}
+
+// Whether the requested disruptor is a registered AlwaysDisruptor
+func (d disruptorRequest) always() bool {
+	_, ok := newRegisteredDisruptor(d.name).(AlwaysDisruptor)
+	return ok
+}
+
+// Applies the configuration on the disruptor, if any was given.  If the
+// configuration had scope keys, the disruptor is returned wrapped with its
+// scope.  It panics if the disruptor does not accept configurations, or
+// if the configuration fails
+func (d disruptorRequest) configure(disruptor Disruptor) Disruptor {
+	scope, conf, err := parseDisruptorScope(d.conf)
+	if err != nil {
+		panic(fmt.Sprintf("Failed scope configuration for %q: %v", d.name, err))
+	}
+	if conf != "" {
+		configurer, ok := disruptor.(DisruptorConfigurer)
+		if !ok {
+			panic(fmt.Sprintf("Disruptor %q does not accept configuration", d.name))
+		}
+		if err := configurer.Configure(conf); err != nil {
+			panic(fmt.Sprintf("Failed configuration for %q: %v", d.name, err))
+		}
+	}
+	if scope != nil {
+		if err := scope.check(disruptor); err != nil {
+			panic(fmt.Sprintf("Failed scope configuration for %q: %v", d.name, err))
+		}
+		return scopedDisruptor{Disruptor: disruptor, scope: scope}
+	}
+	return disruptor
+}
+
+// Limits where a disruptor applies; see ENV_DISRUPTOR
+type disruptorScope struct {
+	idGlob   string
+	idRe     *regexp.Regexp
+	nameGlob string
+	nameRe   *regexp.Regexp
+	kind     string // validator, modify or empty for both
+}
+
+// Separates the scope keys from the disruptor's own configuration.  The
+// returned scope is nil if there were no scope keys
+func parseDisruptorScope(conf string) (*disruptorScope, string, error) {
+	if conf == "" {
+		return nil, "", nil
+	}
+	var scope *disruptorScope
+	seen := map[string]bool{}
+	rest := []string{}
+	for _, item := range strings.Split(conf, ",") {
+		key, value, _ := strings.Cut(item, "=")
+		if !strings.HasPrefix(key, "scope-") {
+			rest = append(rest, item)
+			continue
+		}
+		if seen[key] {
+			return nil, "", fmt.Errorf("repeated scope key %q", key)
+		}
+		seen[key] = true
+		if scope == nil {
+			scope = &disruptorScope{}
+		}
+		var err error
+		switch key {
+		case "scope-id":
+			_, err = path.Match(value, "")
+			scope.idGlob = value
+		case "scope-id-re":
+			scope.idRe, err = regexp.Compile(value)
+		case "scope-name":
+			_, err = path.Match(value, "")
+			scope.nameGlob = value
+		case "scope-name-re":
+			scope.nameRe, err = regexp.Compile(value)
+		case "scope-kind":
+			if value != "validator" && value != "modify" {
+				err = fmt.Errorf("scope-kind must be validator or modify; got %q", value)
+			}
+			scope.kind = value
+		default:
+			err = fmt.Errorf("unknown scope key %q", key)
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return scope, strings.Join(rest, ","), nil
+}
+
+// Checks that the disruptor can honor the scope's kind: only Inspectors act
+// on the step's Modify, while the validators can also be disrupted by a
+// ValidationResultHook
+func (s *disruptorScope) check(d Disruptor) error {
+	if s == nil || s.kind == "" {
+		return nil
+	}
+	if _, ok := d.(Inspector); ok {
+		return nil
+	}
+	if _, ok := d.(ValidationResultHook); ok && s.kind == "validator" {
+		return nil
+	}
+	return fmt.Errorf("scope-kind=%s is not supported by %T, which does not act on the step's %s", s.kind, d, s.kind)
+}
+
+// Whether a step with the given runner ID and name is in the scope
+func (s *disruptorScope) matches(id, name string) bool {
+	if s == nil {
+		return true
+	}
+	if s.idGlob != "" {
+		if ok, _ := path.Match(s.idGlob, id); !ok {
+			return false
+		}
+	}
+	if s.idRe != nil && !s.idRe.MatchString(id) {
+		return false
+	}
+	if s.nameGlob != "" {
+		if ok, _ := path.Match(s.nameGlob, name); !ok {
+			return false
+		}
+	}
+	if s.nameRe != nil && !s.nameRe.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// A disruptor configured with a scope.  The framework unwraps it before
+// checking for the hook interfaces; see Run.getDisruptors
+type scopedDisruptor struct {
+	Disruptor
+	scope *disruptorScope
+}
+
+// Calls the Inspector on the step, if the step is in the scope.  With
+// scope-kind, the parts of the step that are out of the scope are hidden
+// from the Inspector
+func (s scopedDisruptor) inspect(step *Step, phase *Phase, id string) {
+	inspector, ok := s.Disruptor.(Inspector)
+	if !ok || !s.scope.matches(id, step.Name) {
+		return
+	}
+	kind := ""
+	if s.scope != nil {
+		kind = s.scope.kind
+	}
+	switch kind {
+	case "validator":
+		if len(step.GetValidators()) == 0 {
+			return
+		}
+		modify := step.Modify
+		step.Modify = nil
+		inspector.Inspect(step, phase)
+		step.Modify = modify
+	case "modify":
+		if step.Modify == nil {
+			return
+		}
+		validator, validators := step.Validator, step.Validators
+		step.Validator, step.Validators = nil, nil
+		inspector.Inspect(step, phase)
+		step.Validator, step.Validators = validator, validators
+	default:
+		inspector.Inspect(step, phase)
+	}
+}
+
+// Whether the validation result hook applies to the step: validation
+// results are out of a scope-kind=modify
+func (s scopedDisruptor) validationInScope(step Step, id string) bool {
+	if s.scope == nil {
+		return true
+	}
+	return s.scope.kind != "modify" && s.scope.matches(id, step.Name)
+}
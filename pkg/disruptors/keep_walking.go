@@ -9,10 +9,9 @@ import (
 // Ignore any validator failures, and just keep going
 // no mater what.
 //
-// TODO:
-//   - Make it configurable: Validator, Modifier, both
-//   - Make it configurable: select items per ID
-//     (such as SubT0.m0.p0.s1.m0.p0.s0.m0.p0.s0.m0)
+// To limit it to some steps, use the scope keys described on
+// frame2.ENV_DISRUPTOR; for example, KEEP_WALKING:scope-id=SubT0.*
+// or KEEP_WALKING:scope-name=some-step
 type KeepWalking struct {
 }
 
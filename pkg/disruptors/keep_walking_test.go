@@ -1,9 +1,12 @@
 package disruptors_test
 
 import (
-	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+
 	frame2 "github.com/hash-d/frame2/pkg"
 	"github.com/hash-d/frame2/pkg/disruptors"
 	"gotest.tools/assert"
@@ -72,3 +75,52 @@ func TestKeepWalking(t *testing.T) {
 	assert.Assert(t, p0.Run())
 
 }
+
+func TestKeepWalkingScope(t *testing.T) {
+	for _, item := range []struct {
+		conf    string
+		succeed bool
+	}{
+		{"KEEP_WALKING", true},
+		{"KEEP_WALKING:scope-id=*.s0", true},
+		{"KEEP_WALKING:scope-id-re=s0$", true},
+		{"KEEP_WALKING:scope-id=*.s1", false},
+		{"KEEP_WALKING:scope-kind=validator", true},
+	} {
+		t.Setenv(frame2.ENV_DISRUPTOR, item.conf)
+		// No testing.T, so the failures do not fail this test
+		r := &frame2.Run{}
+		r.AllowDisruptors([]frame2.Disruptor{&disruptors.KeepWalking{}})
+		p := frame2.Phase{
+			Runner: r,
+			MainSteps: []frame2.Step{
+				{
+					Validator: &f2general.Executor{
+						Executor: f2general.Fail{
+							Reason: "Only saved if KEEP_WALKING applies to this step",
+						},
+					},
+				}, {
+					Validator: &f2general.Executor{
+						Executor: f2general.Success{},
+					},
+				},
+			},
+		}
+		err := p.Run()
+		assert.Equal(t, err == nil, item.succeed, "%s: %v", item.conf, err)
+	}
+}
+
+// KEEP_WALKING only acts on validation results, so it cannot be limited to
+// the steps' Modify
+func TestKeepWalkingModifyScope(t *testing.T) {
+	t.Setenv(frame2.ENV_DISRUPTOR, "KEEP_WALKING:scope-kind=modify")
+	defer func() {
+		p := recover()
+		assert.Assert(t, p != nil, "scope-kind=modify should be refused")
+		assert.Assert(t, strings.Contains(fmt.Sprint(p), "scope-kind=modify is not supported"), p)
+	}()
+	r := &frame2.Run{}
+	r.AllowDisruptors([]frame2.Disruptor{&disruptors.KeepWalking{}})
+}
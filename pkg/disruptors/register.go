@@ -0,0 +1,8 @@
+package disruptors
+
+import frame2 "github.com/hash-d/frame2/pkg"
+
+func init() {
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &KeepWalking{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &MinAllows{} })
+}
@@ -0,0 +1,77 @@
+package disruptors_test
+
+import (
+	"errors"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	_ "github.com/hash-d/frame2/pkg/disruptors"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"gotest.tools/assert"
+)
+
+// Makes validators be retried at least twice, on any test
+type alwaysAllow struct{}
+
+func (a alwaysAllow) DisruptorEnvValue() string {
+	return "TEST_ALWAYS_ALLOW"
+}
+
+func (a alwaysAllow) AlwaysDisruptor() {}
+
+func (a alwaysAllow) Inspect(step *frame2.Step, phase *frame2.Phase) {
+	if step.ValidatorRetry.Allow < 2 {
+		step.ValidatorRetry.Allow = 2
+	}
+}
+
+func init() {
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return alwaysAllow{} })
+}
+
+func TestListDisruptors(t *testing.T) {
+	found := map[string]frame2.DisruptorInfo{}
+	for _, d := range frame2.ListDisruptors() {
+		found[d.Name] = d
+	}
+
+	keepWalking, ok := found["KEEP_WALKING"]
+	assert.Assert(t, ok, "KEEP_WALKING not registered")
+	assert.Assert(t, !keepWalking.Always)
+	assert.Assert(t, !keepWalking.Configurable)
+
+	minAllows, ok := found["MIN_ALLOWS"]
+	assert.Assert(t, ok, "MIN_ALLOWS not registered")
+	assert.Assert(t, !minAllows.Always)
+	assert.Assert(t, minAllows.Configurable)
+	assert.Equal(t, minAllows.Type, "*disruptors.MinAllows")
+
+	always, ok := found["TEST_ALWAYS_ALLOW"]
+	assert.Assert(t, ok, "TEST_ALWAYS_ALLOW not registered")
+	assert.Assert(t, always.Always)
+}
+
+func TestAlwaysDisruptor(t *testing.T) {
+	t.Setenv(frame2.ENV_DISRUPTOR, "TEST_ALWAYS_ALLOW")
+
+	// The test does not call AllowDisruptors, but TEST_ALWAYS_ALLOW still
+	// applies
+	r := &frame2.Run{
+		T: t,
+	}
+	p := frame2.Phase{
+		Runner: r,
+		MainSteps: []frame2.Step{
+			{
+				Doc: "Fails twice, so it needs TEST_ALWAYS_ALLOW to pass",
+				Validator: &f2general.Dummy{
+					Results: []error{errors.New("first"), errors.New("second"), nil},
+				},
+				ValidatorRetry: frame2.RetryOptions{
+					Interval: 1,
+				},
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+}
@@ -8,6 +8,8 @@ import (
 	frame2 "github.com/hash-d/frame2/pkg"
 )
 
+// Ensures that all validators are retried at least the configured number of
+// times (ValidatorRetry.Allow)
 type MinAllows struct {
 	MinAllows int
 }
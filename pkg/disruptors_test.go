@@ -0,0 +1,67 @@
+package frame2
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestDisruptorScope(t *testing.T) {
+	scope, conf, err := parseDisruptorScope("mode=x,scope-id=SubT0.*,user=y,scope-name-re=^install-")
+	assert.Assert(t, err)
+	assert.Equal(t, conf, "mode=x,user=y")
+	assert.Assert(t, scope.matches("SubT0.m0.p0.s1", "install-pub"))
+	assert.Assert(t, !scope.matches("SubT1.m0.p0.s1", "install-pub"))
+	assert.Assert(t, !scope.matches("SubT0.m0.p0.s1", "remove-pub"))
+
+	scope, conf, err = parseDisruptorScope("3")
+	assert.Assert(t, err)
+	assert.Assert(t, scope == nil)
+	assert.Equal(t, conf, "3")
+
+	for _, invalid := range []string{
+		"scope-kind=other",
+		"scope-id-re=(",
+		"scope-id=[",
+		"scope-unknown=x",
+		"scope-id=a,scope-id=b",
+	} {
+		_, _, err := parseDisruptorScope(invalid)
+		assert.Assert(t, err != nil, invalid)
+	}
+}
+
+type resultHookDisruptor struct{}
+
+func (resultHookDisruptor) DisruptorEnvValue() string {
+	return "RESULT_HOOK"
+}
+
+func (resultHookDisruptor) ValidationResultHook(runner *Run, step Step, err error) error {
+	return nil
+}
+
+type inspectorDisruptor struct{}
+
+func (inspectorDisruptor) DisruptorEnvValue() string {
+	return "INSPECTOR"
+}
+
+func (inspectorDisruptor) Inspect(step *Step, phase *Phase) {}
+
+func TestDisruptorScopeKind(t *testing.T) {
+	for _, item := range []struct {
+		disruptor Disruptor
+		kind      string
+		ok        bool
+	}{
+		{resultHookDisruptor{}, "", true},
+		{resultHookDisruptor{}, "validator", true},
+		{resultHookDisruptor{}, "modify", false},
+		{inspectorDisruptor{}, "validator", true},
+		{inspectorDisruptor{}, "modify", true},
+	} {
+		err := (&disruptorScope{kind: item.kind}).check(item.disruptor)
+		assert.Equal(t, err == nil, item.ok, "%T, %q: %v", item.disruptor, item.kind, err)
+	}
+}
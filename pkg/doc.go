@@ -1,10 +1,6 @@
 // TODO
-// - frame2.Logf (f,v)
-// - frame2.LogVerbosef (f,v)
-// - frame2.LogLevelf (frame2.Level, f, v)
 // - Shared namespaces
 // - Redo Retry testing, other meta_test with Runner?
-// - Example of generating Runner table from simpler table
 // - Example using test-specific Runner (discouraged?)
 // - Example using inter-test communication via references
 // - Auto Doc() or require Doc
@@ -0,0 +1,169 @@
+package frame2
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The markup produced by WriteDoc
+type DocFormat int
+
+const (
+	DocMarkdown DocFormat = iota
+	// reStructuredText, as used by the Sphinx docs under doc/source
+	DocRST
+)
+
+// Writes a description of a test, made of the runner's Doc and the given
+// phases, with their names, Docs and frames (such as f2skupper1.Connect or
+// f2k8s.Curl), for reviewers who do not want to read the Go code.
+//
+// The phases are not run: they are only walked, so frames that build
+// their own phases on Execute are shown as a single item.  Modify steps
+// that are a Phase are expanded, though.
+//
+// title is used as the top heading; if empty, the name of the runner's
+// test is used
+func (r *Run) WriteDoc(w io.Writer, format DocFormat, title string, phases ...Phase) error {
+	d := docWriter{w: w, format: format}
+	if title == "" && r != nil && r.T != nil {
+		title = r.T.Name()
+	}
+	if title != "" {
+		d.heading(0, title)
+	}
+	if r != nil && r.Doc != "" {
+		d.paragraph(r.Doc)
+	}
+	for i, phase := range phases {
+		name := phase.Name
+		if name == "" {
+			name = fmt.Sprintf("Phase %d", i+1)
+		}
+		d.heading(1, name)
+		d.phase(phase, 0)
+	}
+	return d.err
+}
+
+// Does the markup for WriteDoc, keeping the first write error
+type docWriter struct {
+	w      io.Writer
+	format DocFormat
+	err    error
+}
+
+func (d *docWriter) printf(format string, a ...any) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, format, a...)
+}
+
+func (d *docWriter) heading(level int, text string) {
+	if d.format == DocRST {
+		underline := strings.Repeat([]string{"=", "-", "~"}[min(level, 2)], len(text))
+		d.printf("%s\n%s\n\n", text, underline)
+		return
+	}
+	d.printf("%s %s\n\n", strings.Repeat("#", level+1), text)
+}
+
+func (d *docWriter) paragraph(text string) {
+	d.printf("%s\n\n", text)
+}
+
+// A list item; both formats take nested items indented by two spaces.
+// RST needs blank lines around nested lists, so its lists are always
+// separated by them
+func (d *docWriter) item(depth int, text string) {
+	d.printf("%s- %s\n", strings.Repeat("  ", depth), text)
+	if d.format == DocRST {
+		d.printf("\n")
+	}
+}
+
+func (d *docWriter) code(text string) string {
+	if d.format == DocRST {
+		return "``" + text + "``"
+	}
+	return "`" + text + "`"
+}
+
+// Lists the phase's steps, starting at the given list depth
+func (d *docWriter) phase(p Phase, depth int) {
+	if p.Doc != "" {
+		if depth == 0 {
+			d.paragraph(p.Doc)
+		} else {
+			d.item(depth, p.Doc)
+		}
+	}
+	for _, list := range []struct {
+		name  string
+		steps []Step
+	}{
+		{"Setup", p.Setup},
+		{"Main steps", p.MainSteps},
+		{"Teardown", p.Teardown},
+	} {
+		if len(list.steps) == 0 {
+			continue
+		}
+		d.item(depth, "**"+list.name+"**")
+		for _, step := range list.steps {
+			d.step(step, depth+1)
+		}
+	}
+	if depth == 0 && d.format == DocMarkdown {
+		// Ends the list
+		d.printf("\n")
+	}
+}
+
+func (d *docWriter) step(step Step, depth int) {
+	var parts []string
+	if step.Name != "" {
+		parts = append(parts, "**"+step.Name+"**:")
+	}
+	if step.Doc != "" {
+		parts = append(parts, step.Doc)
+	}
+	var frames []string
+	if step.Modify != nil {
+		frames = append(frames, d.code(docFrameName(step.Modify)))
+	}
+	var validators []string
+	for _, v := range step.GetValidators() {
+		validators = append(validators, d.code(docFrameName(v)))
+	}
+	if len(validators) > 0 {
+		frames = append(frames, "validated by "+strings.Join(validators, ", "))
+	}
+	if len(frames) > 0 {
+		parts = append(parts, "("+strings.Join(frames, "; ")+")")
+	}
+	if step.Parallel && len(step.GetSubsteps()) > 0 {
+		parts = append(parts, "*(substeps in parallel)*")
+	}
+	if step.SkipWhen {
+		parts = append(parts, "*(skipped)*")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "*(undocumented step)*")
+	}
+	d.item(depth, strings.Join(parts, " "))
+
+	if phase, ok := step.Modify.(Phase); ok {
+		d.phase(phase, depth+1)
+	}
+	for _, substep := range step.GetSubsteps() {
+		d.step(*substep, depth+1)
+	}
+}
+
+// The frame's type, without the pointer marker; such as f2k8s.Curl
+func docFrameName(frame any) string {
+	return strings.TrimLeft(frameName(frame), "*")
+}
@@ -0,0 +1,96 @@
+package frame2_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"gotest.tools/assert"
+)
+
+func docPhases() []frame2.Phase {
+	return []frame2.Phase{
+		{
+			Name: "install",
+			Doc:  "Installs the thing",
+			Setup: []frame2.Step{
+				{
+					Doc:    "Prepare",
+					Modify: &f2general.Cmd{},
+				},
+			},
+			MainSteps: []frame2.Step{
+				{
+					Name:       "check",
+					Doc:        "Check it",
+					Validators: []frame2.Validator{&f2general.Cmd{}},
+					Substeps: []*frame2.Step{
+						{Doc: "Inner"},
+					},
+				},
+			},
+		}, {
+			MainSteps: []frame2.Step{
+				{
+					Modify: frame2.Phase{
+						Doc: "Nested",
+						MainSteps: []frame2.Step{
+							{Doc: "Deep", SkipWhen: true},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestWriteDocMarkdown(t *testing.T) {
+	r := &frame2.Run{Doc: "A test for the docs"}
+	var buf bytes.Buffer
+	assert.Assert(t, r.WriteDoc(&buf, frame2.DocMarkdown, "TestDocs", docPhases()...))
+
+	expected := strings.Join([]string{
+		"# TestDocs",
+		"",
+		"A test for the docs",
+		"",
+		"## install",
+		"",
+		"Installs the thing",
+		"",
+		"- **Setup**",
+		"  - Prepare (`f2general.Cmd`)",
+		"- **Main steps**",
+		"  - **check**: Check it (validated by `f2general.Cmd`)",
+		"    - Inner",
+		"",
+		"## Phase 2",
+		"",
+		"- **Main steps**",
+		"  - (`frame2.Phase`)",
+		"    - Nested",
+		"    - **Main steps**",
+		"      - Deep *(skipped)*",
+		"",
+		"",
+	}, "\n")
+	assert.Equal(t, buf.String(), expected)
+}
+
+func TestWriteDocRST(t *testing.T) {
+	r := &frame2.Run{T: t}
+	var buf bytes.Buffer
+	assert.Assert(t, r.WriteDoc(&buf, frame2.DocRST, "", docPhases()...))
+
+	out := buf.String()
+	for _, expected := range []string{
+		"TestWriteDocRST\n===============\n",
+		"install\n-------\n",
+		"  - Prepare (``f2general.Cmd``)\n\n",
+		"      - Deep *(skipped)*\n",
+	} {
+		assert.Assert(t, strings.Contains(out, expected), "%q not found on:\n%s", expected, out)
+	}
+}
@@ -2,12 +2,14 @@ package frame2
 
 import (
 	"fmt"
-	"os"
 	"strconv"
+
+	"github.com/hash-d/frame2/pkg/env"
 )
 
-// Frame2-specific environment variables
-// TODO: move this to its own 'env' package
+// Frame2-specific environment variables.  Each is registered as a setting
+// on the env package, which also reads them from its config file; see
+// env.ENV_CONFIG_FILE
 
 const (
 	// This sets the 'Allow' parameter of the retry block for the final
@@ -17,14 +19,46 @@ const (
 	ENV_FINAL_RETRY = "SKUPPER_TEST_FINAL_RETRY"
 )
 
+const (
+	// If set to a file path, the structured events generated by the Run
+	// tree (see frame2.Event) will be appended to that file, as JSON
+	// lines
+	ENV_EVENTS_FILE = "SKUPPER_TEST_FRAME2_EVENTS_FILE"
+
+	// If set to a directory, Run.Report() will write a JUnit XML and an
+	// HTML report of the test into it
+	ENV_REPORT_DIR = "SKUPPER_TEST_FRAME2_REPORT_DIR"
+
+	// If set to a terminal (such as /dev/tty), a failing named step will
+	// prompt on it for an action: continue ignoring the error, hold,
+	// kill (skip the teardown) or finish (run the teardown)
+	ENV_INTERACTIVE = "SKUPPER_TEST_FRAME2_INTERACTIVE"
+
+	// If defined, phases are not run, but only walked: each step's runner
+	// ID, Doc and frames are logged, along with the disruptors that would
+	// change it.  Nothing is executed or validated
+	ENV_PLAN = "SKUPPER_TEST_FRAME2_PLAN"
+
+	// The minimum level for the leveled logs (see Logf): debug, info, warn
+	// or error, optionally followed by per-package levels, as in
+	// "warn,f2k8s=debug,f2skupper1=info".  The default is info, or debug
+	// if SKUPPER_TEST_FRAME2_VERBOSE is set
+	ENV_LOG_LEVEL = "SKUPPER_TEST_FRAME2_LOG_LEVEL"
+
+	// If set to a directory, each test, named phase and named step gets
+	// a directory under it, with its log output, the command outputs and
+	// a summary; see Run.ArtifactDir
+	ENV_ARTIFACT_DIR = "SKUPPER_TEST_FRAME2_ARTIFACT_DIR"
+)
+
 const (
 	// If defined, both stdout and stderr of all issued skupper commands
 	// will be shown on the test output, even if they did not fail
 	ENV_CLI_VERBOSE_COMMANDS = "SKUPPER_TEST_CLI_VERBOSE_COMMANDS"
 )
 
-// TODO: Move all skupper-specific variables to a skupper-specific file, on a
-// skupper-specific package
+// Skupper-specific variables; they are registered as settings by the
+// f2skupper1 packages that use them
 const (
 
 	// Define the upgrade strategy used by the Upgrade disruptor (possibly
@@ -124,18 +158,96 @@ var EnvOldMap = map[string]string{
 	EnvOldOauthProxyRegistryEnvKey:         "OAUTH_PROXY_IMAGE_REGISTRY",
 }
 
+// The settings for the variables above, and for those defined elsewhere
+// on this package
+var (
+	settingFinalRetry = env.Register(env.Setting{
+		Name:        ENV_FINAL_RETRY,
+		Type:        env.Int,
+		Default:     "1",
+		Description: "the number of retries for the final validations, at the end of the test",
+		Package:     "frame2",
+	})
+	settingEventsFile = env.Register(env.Setting{
+		Name:        ENV_EVENTS_FILE,
+		Description: "a file to append the structured events of the Run tree to, as JSON lines",
+		Package:     "frame2",
+	})
+	settingReportDir = env.Register(env.Setting{
+		Name:        ENV_REPORT_DIR,
+		Description: "a directory for the JUnit XML and HTML reports of the test",
+		Package:     "frame2",
+	})
+	settingInteractive = env.Register(env.Setting{
+		Name:        ENV_INTERACTIVE,
+		Description: "a terminal (such as /dev/tty) to prompt on for an action when a named step fails",
+		Package:     "frame2",
+	})
+	settingPlan = env.Register(env.Setting{
+		Name:        ENV_PLAN,
+		Type:        env.Flag,
+		Description: "only walk the phases, logging their steps, without running them",
+		Package:     "frame2",
+	})
+	settingLogLevel = env.Register(env.Setting{
+		Name:        ENV_LOG_LEVEL,
+		Description: "the minimum log level, optionally followed by per-package levels, as in warn,f2k8s=debug",
+		Package:     "frame2",
+		Check: func(value string) error {
+			_, err := parseLogLevels(value)
+			return err
+		},
+	})
+	settingArtifactDir = env.Register(env.Setting{
+		Name:        ENV_ARTIFACT_DIR,
+		Description: "a directory for the logs, command outputs and summaries of each test, named phase and named step",
+		Package:     "frame2",
+	})
+	settingCliVerboseCommands = env.Register(env.Setting{
+		Name:        ENV_CLI_VERBOSE_COMMANDS,
+		Type:        env.Flag,
+		Description: "show the output of all skupper commands, even if they did not fail; any value, even empty, enables it",
+		Package:     "frame2",
+	})
+	settingDisruptor = env.Register(env.Setting{
+		Name:        ENV_DISRUPTOR,
+		Description: "the disruptors to run, separated by semicolons, each with an optional configuration after a colon",
+		Package:     "frame2",
+	})
+	settingVerbose = env.Register(env.Setting{
+		Name:        EnvFrame2Verbose,
+		Type:        env.Flag,
+		Description: "run all steps as verbose, and log at the debug level",
+		Package:     "frame2",
+	})
+	settingTestId = env.Register(env.Setting{
+		Name:        "TEST_ID",
+		Description: "identifies the execution, shared by all test binaries of a go test invocation; a new UUID if not set",
+		Package:     "frame2",
+	})
+)
+
+// Checks all registered settings, and the config file.  It is called at
+// the start of each phase run, so invalid values fail the test before
+// anything is done
+func validateSettings() error {
+	if err := env.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
 func IsVerboseCommandOutput() bool {
-	_, showVerbose := os.LookupEnv(ENV_CLI_VERBOSE_COMMANDS)
-	return showVerbose
+	return settingCliVerboseCommands.IsSet()
 }
 
-// Returns the integer value of the named variable; returns the default value if not
+// Returns the integer value of the named variable, from the environment or
+// the config file (see env.Lookup); returns the default value if not
 // defined or empty.  If there is a value and not an int, panic
 //
-// TODO: once this file is moved to a package 'env', the function name 'env.GetInt'
-// will make more sense
+// For registered settings, prefer env.Setting.Int
 func GetInt(name string, default_ int) int {
-	val, found := os.LookupEnv(name)
+	val, found := env.Lookup(name)
 	if !found || val == "" {
 		return default_
 	}
@@ -0,0 +1,334 @@
+// Package env is the registry of the settings that configure frame2 and
+// the frames built on it, such as SKUPPER_TEST_FINAL_RETRY.
+//
+// Each setting is registered once, by the package that uses it, with its
+// type, default value and description.  Their values come from the
+// environment or, if not set there, from the YAML file named on
+// ENV_CONFIG_FILE, which maps setting names to their values:
+//
+//	SKUPPER_TEST_FINAL_RETRY: 3
+//	SKUPPER_TEST_FRAME2_LOG_LEVEL: warn,f2k8s=debug
+//
+// The environment always takes precedence over the file.
+//
+// Validate checks all registered settings at once, so that a typo on an
+// integer value fails the test at its start, and not when the setting is
+// first used.  WriteHelp lists them all, with their current values
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// The type of a setting's value, which decides how it is validated
+type Type int
+
+const (
+	String Type = iota
+	Int
+	Duration
+	// Flags are enabled by any non-empty value, even "false" or "0", as
+	// frame2's "if defined" variables always were
+	Flag
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int:
+		return "int"
+	case Duration:
+		return "duration"
+	case Flag:
+		return "flag"
+	}
+	return "string"
+}
+
+type Setting struct {
+	// The environment variable, which is also the key on the config file
+	Name string
+	Type Type
+	// Used when the setting is not set, or set to an empty value
+	Default     string
+	Description string
+	// The package that registered and uses the setting, such as frame2 or
+	// f2skupper1
+	Package string
+
+	// If not empty, the only values accepted (besides the empty value)
+	Values []string
+
+	// Additional validation of non-empty values, after the type's
+	Check func(value string) error
+}
+
+// Returns the setting's value, as given on the environment or on the
+// config file, and whether it was found on either.  A setting set to an
+// empty value is found
+func (s *Setting) Lookup() (string, bool) {
+	return Lookup(s.Name)
+}
+
+// Whether the setting was given, even if with an empty value
+func (s *Setting) IsSet() bool {
+	_, ok := s.Lookup()
+	return ok
+}
+
+// Returns the setting's value, or its default if not set or empty
+func (s *Setting) Value() string {
+	if v, _ := s.Lookup(); v != "" {
+		return v
+	}
+	return s.Default
+}
+
+// Returns the value of an Int setting.  It panics if the value is not an
+// integer; use Validate to catch that at the start of the test
+func (s *Setting) Int() int {
+	v := s.Value()
+	if v == "" {
+		return 0
+	}
+	ret, err := strconv.Atoi(v)
+	if err != nil {
+		panic(fmt.Sprintf("variable %q has non-integer value %q", s.Name, v))
+	}
+	return ret
+}
+
+// Returns the value of a Duration setting, in the format of
+// time.ParseDuration.  It panics if the value is invalid; use Validate to
+// catch that at the start of the test
+func (s *Setting) Duration() time.Duration {
+	v := s.Value()
+	if v == "" {
+		return 0
+	}
+	ret, err := time.ParseDuration(v)
+	if err != nil {
+		panic(fmt.Sprintf("variable %q has invalid duration %q", s.Name, v))
+	}
+	return ret
+}
+
+// Whether a Flag setting is enabled: its value, or its default, is not
+// empty
+func (s *Setting) Enabled() bool {
+	return s.Value() != ""
+}
+
+// Checks the setting's current value against its type, Values and Check
+func (s *Setting) Validate() error {
+	v, _ := s.Lookup()
+	if v == "" {
+		return nil
+	}
+	var err error
+	switch s.Type {
+	case Int:
+		_, err = strconv.Atoi(v)
+	case Duration:
+		_, err = time.ParseDuration(v)
+	}
+	if err == nil && len(s.Values) > 0 && !slices.Contains(s.Values, v) {
+		err = fmt.Errorf("must be one of %s", strings.Join(s.Values, ", "))
+	}
+	if err == nil && s.Check != nil {
+		err = s.Check(v)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q for %s: %w", s.Type, v, s.Name, err)
+	}
+	return nil
+}
+
+var (
+	lock     sync.Mutex
+	settings = map[string]*Setting{}
+)
+
+// Adds the setting to the registry, and returns it, for the registering
+// package to read its value.  It is meant to be called on package-level
+// variable declarations; registering the same name twice panics
+func Register(s Setting) *Setting {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := settings[s.Name]; ok {
+		panic(fmt.Sprintf("setting %q registered twice", s.Name))
+	}
+	settings[s.Name] = &s
+	return &s
+}
+
+// Returns the registered setting with that name, or nil
+func Get(name string) *Setting {
+	lock.Lock()
+	defer lock.Unlock()
+	return settings[name]
+}
+
+// Returns all registered settings, sorted by package and name
+func All() []*Setting {
+	lock.Lock()
+	defer lock.Unlock()
+	ret := make([]*Setting, 0, len(settings))
+	for _, s := range settings {
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Package != ret[j].Package {
+			return ret[i].Package < ret[j].Package
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Returns the value of the named variable from the environment or, if
+// not set there, from the config file.  It works for names that were not
+// registered as well
+func Lookup(name string) (string, bool) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true
+	}
+	values, _ := fileValues()
+	v, ok := values[name]
+	return v, ok
+}
+
+// Checks the config file and the values of all registered settings,
+// returning an error that lists every problem found
+func Validate() error {
+	var errs []error
+	if _, err := fileValues(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, s := range All() {
+		if err := s.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Lists all registered settings, by package, with their current values,
+// types, defaults and descriptions
+func WriteHelp(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Settings, from the environment or from the file on %s:\n", ENV_CONFIG_FILE)
+	var pkg string
+	for _, s := range All() {
+		if s.Package != pkg {
+			pkg = s.Package
+			fmt.Fprintf(&b, "\n%s:\n", pkg)
+		}
+		current := "(not set)"
+		if v, ok := s.Lookup(); ok {
+			current = strconv.Quote(v)
+		}
+		details := s.Type.String()
+		if s.Default != "" {
+			details += ", default " + strconv.Quote(s.Default)
+		}
+		if len(s.Values) > 0 {
+			details += ", one of " + strings.Join(s.Values, "|")
+		}
+		fmt.Fprintf(&b, "  %s = %s (%s)\n", s.Name, current, details)
+		if s.Description != "" {
+			fmt.Fprintf(&b, "      %s\n", s.Description)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// A YAML file with a map of setting names to their values
+const ENV_CONFIG_FILE = "SKUPPER_TEST_FRAME2_CONFIG"
+
+var configFile = Register(Setting{
+	Name:        ENV_CONFIG_FILE,
+	Description: "a YAML file with values for these settings; the environment takes precedence over it",
+	Package:     "env",
+})
+
+var (
+	fileLock   sync.Mutex
+	fileLoaded bool
+	fileData   map[string]string
+	fileErr    error
+)
+
+// Loads the config file on first use, from ENV_CONFIG_FILE
+func fileValues() (map[string]string, error) {
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	if !fileLoaded {
+		fileData, fileErr = readFile(os.Getenv(configFile.Name))
+		fileLoaded = true
+	}
+	return fileData, fileErr
+}
+
+// Replaces the config file, overriding ENV_CONFIG_FILE.  An empty path
+// removes it, so that only the environment is used.  On errors, the
+// previous file is kept
+func LoadFile(path string) error {
+	data, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	fileLock.Lock()
+	defer fileLock.Unlock()
+	fileData, fileErr, fileLoaded = data, nil, true
+	return nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config file: %w", err)
+	}
+	asJSON, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the config file %q: %w", path, err)
+	}
+	// json.Number keeps the numbers as written, so that 3 does not become
+	// 3.0, nor 1000000 become 1e+06
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(asJSON))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("the config file %q must be a map of setting names to values: %w", path, err)
+	}
+	ret := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case nil:
+			ret[k] = ""
+		case string:
+			ret[k] = v
+		case json.Number, bool:
+			ret[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("the config file %q has a non-scalar value for %q", path, k)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,111 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+var (
+	testInt = Register(Setting{
+		Name:    "FRAME2_ENV_TEST_INT",
+		Type:    Int,
+		Default: "7",
+		Package: "env_test",
+	})
+	testDuration = Register(Setting{
+		Name:    "FRAME2_ENV_TEST_DURATION",
+		Type:    Duration,
+		Package: "env_test",
+	})
+	testChoice = Register(Setting{
+		Name:        "FRAME2_ENV_TEST_CHOICE",
+		Values:      []string{"a", "b"},
+		Description: "a or b",
+		Package:     "env_test",
+	})
+	testFlag = Register(Setting{
+		Name:    "FRAME2_ENV_TEST_FLAG",
+		Type:    Flag,
+		Package: "env_test",
+	})
+)
+
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.Assert(t, os.WriteFile(path, []byte(content), 0644))
+	assert.Assert(t, LoadFile(path))
+	t.Cleanup(func() { _ = LoadFile("") })
+}
+
+func TestSettings(t *testing.T) {
+	assert.Assert(t, LoadFile(""))
+	for _, s := range All() {
+		if s.Package == "env_test" {
+			t.Setenv(s.Name, "")
+			os.Unsetenv(s.Name)
+		}
+	}
+
+	assert.Equal(t, testInt.Int(), 7)
+	assert.Equal(t, testDuration.Duration(), time.Duration(0))
+	assert.Assert(t, !testFlag.Enabled())
+	assert.Assert(t, !testChoice.IsSet())
+	assert.Assert(t, Validate())
+
+	// The file is used when the environment does not have the setting
+	writeConfig(t, "FRAME2_ENV_TEST_INT: 1000000\nFRAME2_ENV_TEST_DURATION: 2m\nFRAME2_ENV_TEST_FLAG: true\nFRAME2_ENV_TEST_CHOICE:\n")
+	assert.Equal(t, testInt.Int(), 1000000)
+	assert.Equal(t, testDuration.Duration(), 2*time.Minute)
+	assert.Assert(t, testFlag.Enabled())
+	value, ok := testChoice.Lookup()
+	assert.Assert(t, ok)
+	assert.Equal(t, value, "")
+
+	// The environment takes precedence
+	t.Setenv(testInt.Name, "3")
+	assert.Equal(t, testInt.Int(), 3)
+	t.Setenv(testInt.Name, "")
+	assert.Equal(t, testInt.Int(), 7)
+
+	assert.Equal(t, Get(testChoice.Name), testChoice)
+	assert.Assert(t, Get("FRAME2_ENV_TEST_MISSING") == nil)
+}
+
+func TestValidate(t *testing.T) {
+	assert.Assert(t, LoadFile(""))
+	t.Setenv(testInt.Name, "three")
+	t.Setenv(testDuration.Name, "2")
+	t.Setenv(testChoice.Name, "c")
+	err := Validate()
+	assert.ErrorContains(t, err, `invalid int value "three" for FRAME2_ENV_TEST_INT`)
+	assert.ErrorContains(t, err, `invalid duration value "2" for FRAME2_ENV_TEST_DURATION`)
+	assert.ErrorContains(t, err, `invalid string value "c" for FRAME2_ENV_TEST_CHOICE: must be one of a, b`)
+
+	t.Setenv(testInt.Name, "3")
+	t.Setenv(testDuration.Name, "")
+	t.Setenv(testChoice.Name, "a")
+	assert.Assert(t, Validate())
+
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	assert.Assert(t, os.WriteFile(path, []byte("- a list"), 0644))
+	assert.ErrorContains(t, LoadFile(path), "must be a map")
+	assert.ErrorContains(t, LoadFile(filepath.Join(t.TempDir(), "missing.yaml")), "failed to read the config file")
+}
+
+func TestWriteHelp(t *testing.T) {
+	t.Setenv(testChoice.Name, "b")
+	var b strings.Builder
+	assert.Assert(t, WriteHelp(&b))
+	help := b.String()
+	assert.Assert(t, strings.Contains(help, "\nenv_test:\n"), help)
+	assert.Assert(t, strings.Contains(help, "  FRAME2_ENV_TEST_CHOICE = \"b\" (string, one of a|b)\n      a or b\n"), help)
+	assert.Assert(t, strings.Contains(help, "  FRAME2_ENV_TEST_INT = "), help)
+	assert.Assert(t, strings.Contains(help, "(int, default \"7\")"), help)
+	assert.Assert(t, strings.Contains(help, ENV_CONFIG_FILE), help)
+}
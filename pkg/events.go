@@ -0,0 +1,220 @@
+package frame2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// The kinds of events generated by the Run tree.  Start and end events are
+// always paired; the end event carries the duration and the error (if any)
+type EventKind string
+
+const (
+	EventPhaseStart     EventKind = "phase-start"
+	EventPhaseEnd       EventKind = "phase-end"
+	EventStepStart      EventKind = "step-start"
+	EventStepEnd        EventKind = "step-end"
+	EventModifyStart    EventKind = "modify-start"
+	EventModifyEnd      EventKind = "modify-end"
+	EventValidatorStart EventKind = "validator-start"
+	EventValidatorEnd   EventKind = "validator-end"
+	EventRetryAttempt   EventKind = "retry-attempt"
+	EventHookStart      EventKind = "hook-start"
+	EventHookEnd        EventKind = "hook-end"
+	EventTeardownStart  EventKind = "teardown-start"
+	EventTeardownEnd    EventKind = "teardown-end"
+)
+
+// An Event describes something that happened on the Run tree.  They're
+// generated by the framework itself, and sent to any registered EventSink
+type Event struct {
+	Kind       EventKind
+	Time       time.Time
+	RunnerId   string     // As returned by Run.GetId()
+	RunnerType RunnerType // The type of the Runner that generated the event
+	Test       string     // The name of the testing.T, if any
+	Name       string     // The name of the phase or step, if any
+	Doc        string
+	Frame      string // The type of the Executor or Validator, if any
+	Attempt    int    // For retry attempts, the attempt number (starting at 1)
+	Duration   time.Duration
+	Err        error
+}
+
+// Renders the Err as a string, the Duration as seconds and the
+// RunnerType by its name.  Errors that are an ErrSkip are also flagged as
+// skipped
+func (e Event) MarshalJSON() ([]byte, error) {
+	var errString string
+	if e.Err != nil {
+		errString = e.Err.Error()
+	}
+	_, skipped := AsSkip(e.Err)
+	return json.Marshal(struct {
+		Kind       EventKind `json:"kind"`
+		Time       time.Time `json:"time"`
+		RunnerId   string    `json:"runnerId"`
+		RunnerType string    `json:"runnerType"`
+		Test       string    `json:"test,omitempty"`
+		Name       string    `json:"name,omitempty"`
+		Doc        string    `json:"doc,omitempty"`
+		Frame      string    `json:"frame,omitempty"`
+		Attempt    int       `json:"attempt,omitempty"`
+		Duration   float64   `json:"duration,omitempty"`
+		Err        string    `json:"error,omitempty"`
+		Skipped    bool      `json:"skipped,omitempty"`
+	}{
+		Kind:       e.Kind,
+		Time:       e.Time,
+		RunnerId:   e.RunnerId,
+		RunnerType: e.RunnerType.String(),
+		Test:       e.Test,
+		Name:       e.Name,
+		Doc:        e.Doc,
+		Frame:      e.Frame,
+		Attempt:    e.Attempt,
+		Duration:   e.Duration.Seconds(),
+		Err:        errString,
+		Skipped:    skipped,
+	})
+}
+
+// An EventSink receives all events generated by the framework, once
+// registered with RegisterEventSink.
+//
+// HandleEvent may be called concurrently (for example, from monitors or
+// parallel steps), so implementations need to be safe for that
+type EventSink interface {
+	HandleEvent(e Event)
+}
+
+var (
+	eventSinks     []EventSink
+	eventSinksLock sync.Mutex
+	eventsFileOnce sync.Once
+)
+
+// Adds the sink to the list of sinks that receive all events.  The returned
+// function removes it from the list.
+func RegisterEventSink(sink EventSink) (unregister func()) {
+	eventSinksLock.Lock()
+	defer eventSinksLock.Unlock()
+	eventSinks = append(eventSinks, sink)
+	return func() {
+		eventSinksLock.Lock()
+		defer eventSinksLock.Unlock()
+		for i, s := range eventSinks {
+			if s == sink {
+				eventSinks = append(eventSinks[:i:i], eventSinks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// If ENV_EVENTS_FILE is set, register a JSON lines sink for it.  This is
+// done on the first event, not on init, so that tests that do not use the
+// Run tree do not create the file
+func registerEnvEventSink() {
+	path := settingEventsFile.Value()
+	if path == "" {
+		return
+	}
+	sink, err := OpenJSONLinesSink(path)
+	if err != nil {
+		log.Printf("[R] failed to open events file %q: %v", path, err)
+		return
+	}
+	RegisterEventSink(sink)
+}
+
+// Sends the event to all registered sinks
+func emitEvent(e Event) {
+	eventsFileOnce.Do(registerEnvEventSink)
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	eventSinksLock.Lock()
+	sinks := append([]EventSink{}, eventSinks...)
+	eventSinksLock.Unlock()
+	for _, s := range sinks {
+		s.HandleEvent(e)
+	}
+}
+
+// Fills the runner information on the event, records it on the runner
+// (for reporting) and emits it
+func (r *Run) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if r != nil {
+		r.record(e)
+		e.RunnerId = r.GetId()
+		e.RunnerType = r.kind
+		if r.T != nil {
+			e.Test = r.T.Name()
+		}
+	}
+	emitEvent(e)
+}
+
+// Returns the type name of a frame, for use on Event.Frame
+func frameName(frame any) string {
+	if frame == nil {
+		return ""
+	}
+	return fmt.Sprintf("%T", frame)
+}
+
+// Writes each event as a JSON object on its own line
+//
+// Use NewJSONLinesSink for any io.Writer, or OpenJSONLinesSink to
+// append to a file
+type JSONLinesSink struct {
+	w      io.Writer
+	closer io.Closer
+	lock   sync.Mutex
+}
+
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// Opens (or creates) the file at path for appending, and returns a sink
+// that writes to it.  As multiple test binaries may write to the same
+// file, each event is written with a single write call
+func OpenJSONLinesSink(path string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLinesSink{w: f, closer: f}, nil
+}
+
+func (j *JSONLinesSink) HandleEvent(e Event) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("[R] failed to marshal event %+v: %v", e, err)
+		return
+	}
+	line = append(line, '\n')
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if _, err := j.w.Write(line); err != nil {
+		log.Printf("[R] failed to write event: %v", err)
+	}
+}
+
+// Closes the underlying file, if the sink was created with OpenJSONLinesSink
+func (j *JSONLinesSink) Close() error {
+	if j.closer == nil {
+		return nil
+	}
+	return j.closer.Close()
+}
@@ -0,0 +1,118 @@
+package frame2_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"gotest.tools/assert"
+)
+
+type eventCollector struct {
+	events []frame2.Event
+	lock   sync.Mutex
+}
+
+func (c *eventCollector) HandleEvent(e frame2.Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.events = append(c.events, e)
+}
+
+func (c *eventCollector) kinds() map[frame2.EventKind]int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := map[frame2.EventKind]int{}
+	for _, e := range c.events {
+		ret[e.Kind]++
+	}
+	return ret
+}
+
+func TestEvents(t *testing.T) {
+	collector := &eventCollector{}
+	unregister := frame2.RegisterEventSink(collector)
+	defer unregister()
+
+	runner := &frame2.Run{T: t}
+	p := frame2.Phase{
+		Runner: runner,
+		Name:   "events",
+		Doc:    "Generate some events",
+		MainSteps: []frame2.Step{
+			{
+				Doc:    "A modify",
+				Modify: &f2general.Success{},
+			}, {
+				Doc: "A validator that needs a retry",
+				Validator: &f2general.Dummy{
+					Results: []error{f2general.Fail{}.Validate(), nil},
+				},
+				ValidatorRetry: frame2.RetryOptions{
+					Allow:    1,
+					Interval: 1,
+				},
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+
+	kinds := collector.kinds()
+	assert.Equal(t, kinds[frame2.EventPhaseStart], 1)
+	assert.Equal(t, kinds[frame2.EventPhaseEnd], 1)
+	assert.Equal(t, kinds[frame2.EventStepStart], 2)
+	assert.Equal(t, kinds[frame2.EventStepEnd], 2)
+	assert.Equal(t, kinds[frame2.EventModifyStart], 1)
+	assert.Equal(t, kinds[frame2.EventModifyEnd], 1)
+	assert.Equal(t, kinds[frame2.EventValidatorEnd], 2)
+	assert.Equal(t, kinds[frame2.EventRetryAttempt], 2)
+
+	for _, e := range collector.events {
+		assert.Assert(t, e.RunnerId != "", "event %+v has no runner ID", e)
+		assert.Assert(t, strings.HasPrefix(e.Test, "TestEvents/events"), "event %+v on unexpected test", e)
+		if e.Kind == frame2.EventRetryAttempt && e.Attempt == 1 {
+			assert.Assert(t, e.Err != nil, "the first attempt should have failed")
+		}
+	}
+
+	// After unregistering, no further events are received
+	unregister()
+	count := len(collector.events)
+	p2 := frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			{
+				Modify: &f2general.Success{},
+			},
+		},
+	}
+	assert.Assert(t, p2.Run())
+	assert.Equal(t, len(collector.events), count)
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := frame2.NewJSONLinesSink(&buf)
+
+	sink.HandleEvent(frame2.Event{
+		Kind:       frame2.EventStepEnd,
+		RunnerId:   "R0.p0.s0",
+		RunnerType: frame2.StepRunner,
+		Err:        f2general.Fail{Reason: "because"}.Validate(),
+	})
+	sink.HandleEvent(frame2.Event{Kind: frame2.EventStepStart})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, len(lines), 2)
+
+	var decoded map[string]any
+	assert.Assert(t, json.Unmarshal([]byte(lines[0]), &decoded))
+	assert.Equal(t, decoded["kind"], "step-end")
+	assert.Equal(t, decoded["runnerId"], "R0.p0.s0")
+	assert.Equal(t, decoded["runnerType"], "step")
+	assert.Assert(t, strings.Contains(decoded["error"].(string), "because"))
+}
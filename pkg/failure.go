@@ -0,0 +1,70 @@
+package frame2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FailureHooks run when a step fails, to collect diagnostics while the
+// environment is still as the failure left it (for example, f2k8s.ClusterDumper
+// saves the state of the test's namespaces).  They should save what they
+// collect on the runner's artifact directory; see Run.ArtifactDir.
+//
+// Hooks are run once per failure: a failure that propagates up through
+// nested steps and phases is reported only where it was first seen.  They
+// are registered with Run.AddFailureHook; disruptors that implement this
+// interface are run as well.
+//
+// Errors returned by the hooks are logged, but do not change the test
+// result
+type FailureHook interface {
+	FailureHook(runner *Run, failure error) error
+}
+
+// Registers a FailureHook for the whole test; it is kept on the root
+// runner
+func (r *Run) AddFailureHook(hook FailureHook) {
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	root := r.getRoot()
+	root.failureHooks = append(root.failureHooks, hook)
+}
+
+// Runs the failure hooks for err, unless it (or an error it wraps) has
+// already been reported
+func (r *Run) runFailureHooks(failure error) {
+	if _, skipped := AsSkip(failure); failure == nil || skipped {
+		return
+	}
+	root := r.getRoot()
+	runnerLock.Lock()
+	for _, reported := range root.reportedFailures {
+		if errors.Is(failure, reported) {
+			runnerLock.Unlock()
+			return
+		}
+	}
+	root.reportedFailures = append(root.reportedFailures, failure)
+	hooks := append([]FailureHook{}, root.failureHooks...)
+	runnerLock.Unlock()
+
+	for _, d := range r.getDisruptors() {
+		if hook, ok := d.(FailureHook); ok {
+			hooks = append(hooks, hook)
+		}
+	}
+	for _, hook := range hooks {
+		hookRunner := r.ChildWithT(r.T, HookRunner)
+		err := hookRunner.runHook("failure-hook", func(hr *Run) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("failure hook %T panicked: %v", hook, p)
+				}
+			}()
+			return hook.FailureHook(hr, failure)
+		})
+		if err != nil {
+			hookRunner.logf(LevelWarn, "[R] %v failure hook %T failed: %v", hookRunner.GetId(), hook, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package frame2_test
+
+import (
+	"errors"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"gotest.tools/assert"
+)
+
+type returnError struct {
+	err error
+}
+
+func (r returnError) Execute() error {
+	return r.err
+}
+
+type failureRecorder struct {
+	ids      []string
+	failures []error
+}
+
+func (f *failureRecorder) FailureHook(runner *frame2.Run, failure error) error {
+	f.ids = append(f.ids, runner.GetId())
+	f.failures = append(f.failures, failure)
+	return errors.New("hook errors are only logged")
+}
+
+func TestFailureHook(t *testing.T) {
+	failure := errors.New("boom")
+	recorder := &failureRecorder{}
+	runner := &frame2.Run{}
+	runner.AddFailureHook(recorder)
+
+	p := frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			{
+				Doc:    "ok",
+				Modify: returnError{},
+			}, {
+				// The failure propagates through both phases, but the hook
+				// is run only on the inner one
+				Modify: frame2.Phase{
+					MainSteps: []frame2.Step{
+						{
+							Modify: returnError{failure},
+						},
+					},
+				},
+			},
+		},
+	}
+	err := p.Run()
+	assert.Assert(t, errors.Is(err, failure))
+	assert.Equal(t, len(recorder.failures), 1)
+	assert.Assert(t, errors.Is(recorder.failures[0], failure))
+	assert.Equal(t, recorder.ids[0], "R0.p0.s1.m0.p0.H1")
+}
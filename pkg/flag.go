@@ -2,19 +2,52 @@ package frame2
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
+
+	"github.com/hash-d/frame2/pkg/env"
 )
 
-// Right now, this will only add the -H flag for
-// showing the flag's help (flag.Usage())
+// Adds the -H flag for showing the flag's help (flag.Usage()) followed by
+// all registered settings with their current values (see env.WriteHelp), the
+// -frame2-interactive flag, which works like ENV_INTERACTIVE, and the
+// -frame2-plan flag, which works like ENV_PLAN
 func Flag() {
 	flag.BoolFunc(
 		"H",
 		"this help screen",
 		func(string) error {
 			flag.Usage()
+			fmt.Fprintln(flag.CommandLine.Output())
+			_ = env.WriteHelp(flag.CommandLine.Output())
 			os.Exit(0)
 			return nil
 		},
 	)
+	flag.Func(
+		"frame2-interactive",
+		"prompt for an action on failing named steps, using the given terminal (such as /dev/tty)",
+		openInteractive,
+	)
+	flag.BoolFunc(
+		"frame2-plan",
+		"do not run the test; only log its steps and the disruptors that would change them",
+		setPlanFlag,
+	)
+}
+
+// Sets ENV_PLAN for -frame2-plan.  A false value, as in -frame2-plan=false,
+// sets it empty instead, which turns the plan mode off even if it was
+// enabled on the environment or on the config file (the environment takes
+// precedence over the file, and empty Flag settings are disabled)
+func setPlanFlag(value string) error {
+	plan, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if !plan {
+		return os.Setenv(ENV_PLAN, "")
+	}
+	return os.Setenv(ENV_PLAN, "1")
 }
@@ -27,7 +27,9 @@ const CmdDefaultTimeout = 2 * time.Minute
 // frame2.Expect configuration.
 //
 // If both AcceptReturn and FailReturn are defined and the return
-// status is not present on either, an error will be returned
+// status is not present on either, an error will be returned.  Such
+// configuration errors are marked with frame2.Permanent, so they are not
+// retried
 //
 // This is basically a wrapper around Go's exec.Cmd, and its configuration
 // even uses that structure, embedded.  There are some differences,
@@ -39,7 +41,9 @@ const CmdDefaultTimeout = 2 * time.Minute
 //     over the context (ie, a timeout context wrapping the original context)
 //   - If neither are provided, there is a default timeout.  If the user
 //     provides their own Context, though, it's up to them to make sure
-//     the command does not run forever.
+//     the command does not run forever.  A context set by the framework
+//     (see frame2.IsFrameworkContext) does not count as the user's: the
+//     default timeout is still applied over it
 type Cmd struct {
 	// The command to be executed, as if exec.Command() had been called (ie, it
 	// looks for the command on the PATH, if no slashes on it).  If empty, then
@@ -90,6 +94,18 @@ func (ce CmdError) Error() string {
 	return fmt.Sprintf("f2.execute.Cmd/cmd: %s(%T), /Expect: %s", ce.Cmd, ce.Cmd, ce.Expect)
 }
 
+// Allows errors.Is and errors.As (and so frame2.IsPermanent) to check both
+// the command and the Expect errors
+func (ce CmdError) Unwrap() []error {
+	var ret []error
+	for _, err := range []error{ce.Cmd, ce.Expect} {
+		if err != nil {
+			ret = append(ret, err)
+		}
+	}
+	return ret
+}
+
 // Change this by Go 1.18's generic slices.Contains?
 func containsInt(needle int, haystack []int) bool {
 	for _, x := range haystack {
@@ -118,10 +134,10 @@ func (c *Cmd) Execute() error {
 	}
 
 	// We'll set a context with timeout in two cases:
-	// - For nil contexts
+	// - For nil or framework contexts
 	// - For explicit requests
-	// If nil context and no explicit timeout request, we set a default
-	if c.Ctx == nil || c.Timeout > 0 {
+	// If nil or framework context and no explicit timeout request, we set a default
+	if c.Ctx == nil || frame2.IsFrameworkContext(c.Ctx) || c.Timeout > 0 {
 		var timeout time.Duration
 
 		if c.Timeout > 0 {
@@ -130,7 +146,7 @@ func (c *Cmd) Execute() error {
 			timeout = CmdDefaultTimeout
 		}
 
-		ctx_, fn := context.WithTimeout(context.Background(), timeout)
+		ctx_, fn := context.WithTimeout(ctx, timeout)
 		ctx = ctx_
 		defer fn()
 	}
@@ -140,7 +156,7 @@ func (c *Cmd) Execute() error {
 	// Preparing the command to run
 	if c.Command == "" {
 		if c.Shell {
-			return fmt.Errorf("execute.Cmd configuration error - shell requested, but empty Command")
+			return frame2.Permanent(fmt.Errorf("execute.Cmd configuration error - shell requested, but empty Command"))
 		}
 		// No command specified; we'll use the exec.Cmd structure as-is, just
 		// overriding the context
@@ -196,6 +212,8 @@ func (c *Cmd) Execute() error {
 	c.CmdResult.Stderr = stderr.String()
 	c.CmdResult.Err = cmdErr
 
+	c.writeArtifacts(cmd, cmdErr)
+
 	if !c.ForceNoOutput {
 		if c.ForceOutput || frame2.IsVerboseCommandOutput() {
 			c.Log.Printf("STDOUT:\n%v\n", stdout.String())
@@ -220,13 +238,13 @@ func (c *Cmd) Execute() error {
 					// Both lists set
 					switch {
 					case containsInt(ret, c.AcceptReturn) && containsInt(ret, c.FailReturn):
-						returnedCmdError = fmt.Errorf("cmd configuration error - the exit code %d is on both accept and fail lists: %w", ret, cmdErr)
+						returnedCmdError = frame2.Permanent(fmt.Errorf("cmd configuration error - the exit code %d is on both accept and fail lists: %w", ret, cmdErr))
 					case containsInt(ret, c.AcceptReturn):
 						returnedCmdError = nil
 					case containsInt(ret, c.FailReturn):
 						returnedCmdError = cmdErr
 					default:
-						returnedCmdError = fmt.Errorf("cmd configuration error - the exit code %d is on either accept nor fail lists: %w", ret, cmdErr)
+						returnedCmdError = frame2.Permanent(fmt.Errorf("cmd configuration error - the exit code %d is on either accept nor fail lists: %w", ret, cmdErr))
 					}
 				} else {
 					// Only AcceptReturn set
@@ -273,3 +291,27 @@ func (c *Cmd) Execute() error {
 
 	return err
 }
+
+// Keeps the command's outputs on the runner's artifact directory, if any
+// (see frame2.Run.ArtifactDir)
+func (c *Cmd) writeArtifacts(cmd *exec.Cmd, cmdErr error) {
+	runner := c.GetRunner()
+	if runner == nil || runner.ArtifactDir() == "" {
+		return
+	}
+	prefix := "cmd-" + runner.GetId()
+	for _, output := range []struct {
+		ext     string
+		content string
+	}{
+		{".stdout", c.CmdResult.Stdout},
+		{".stderr", c.CmdResult.Stderr},
+	} {
+		path, err := runner.WriteArtifact(prefix+output.ext, []byte(output.content))
+		if err != nil {
+			c.Log.Printf("failed to save the command output: %v", err)
+			continue
+		}
+		c.Log.Logf("%s of %q (error: %v) saved to %q", output.ext[1:], strings.Join(cmd.Args, " "), cmdErr, path)
+	}
+}
@@ -32,6 +32,7 @@ type cmdValidator struct {
 	errorOnCommand      bool
 	errorOnExpect       bool
 	nonCmdErr           bool
+	permanent           bool // the error is expected to be marked with frame2.Permanent
 	resultCommunication *CmdResult
 
 	frame2.Log
@@ -56,6 +57,9 @@ func (ct cmdValidator) Validate() error {
 			foundErrors = append(foundErrors, "expected Expect to fail, but it didn't")
 		}
 	} else {
+		if ct.permanent != frame2.IsPermanent(err) {
+			foundErrors = append(foundErrors, fmt.Sprintf("expected permanent error: %t, but got %v", ct.permanent, err))
+		}
 		typedErr, ok := err.(CmdError)
 		if !ok {
 			// This is not a CmdError
@@ -229,6 +233,7 @@ var tests = frame2.Phase{
 				// This should be the only situation where an error other than
 				// CmdErr is returned
 				nonCmdErr: true,
+				permanent: true,
 			},
 		}, {
 			Name: "shell-args",
@@ -375,6 +380,7 @@ var tests = frame2.Phase{
 					FailReturn:   []int{4, 5, 6},
 				},
 				errorOnCommand: true,
+				permanent:      true,
 			},
 		}, {
 			Name: "both-lists-both-nok",
@@ -386,6 +392,7 @@ var tests = frame2.Phase{
 					FailReturn:   []int{3, 4, 5, 6},
 				},
 				errorOnCommand: true,
+				permanent:      true,
 			},
 		}, {
 			Name: "TODO",
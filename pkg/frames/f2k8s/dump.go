@@ -0,0 +1,286 @@
+package f2k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+)
+
+// The namespaced Skupper resources saved by ClusterDumper, when it has no
+// Resources of its own.  Skupper 1 has none: it keeps the site's state on
+// config maps (see SkupperConfigMaps), which are saved regardless.  For
+// Skupper 2 sites, use Skupper2Resources
+var SkupperResources = []schema.GroupVersionResource{}
+
+// The namespaced Skupper 2 resources; set them on ClusterDumper.Resources
+// (or on SkupperResources) to save them.  Those whose CRDs are not
+// installed are skipped
+var Skupper2Resources = []schema.GroupVersionResource{
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "sites"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "links"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "listeners"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "connectors"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "accessgrants"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "accesstokens"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "routeraccesses"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "securedaccesses"},
+	{Group: "skupper.io", Version: "v2alpha1", Resource: "certificates"},
+}
+
+// The cluster-scoped Skupper resources saved by ClusterDumper, when it has
+// no ClusterResources of its own.  Skupper 1 has a single one, its cluster
+// policy
+var SkupperClusterResources = []schema.GroupVersionResource{
+	{Group: "skupper.io", Version: "v1alpha1", Resource: "skupperclusterpolicies"},
+}
+
+// The config maps where Skupper 1 keeps the site's configuration and state.
+// Besides being on configmaps.yaml with all others, ClusterDumper saves each
+// of them on its own file, on skupper/configmap-<name>.yaml
+var SkupperConfigMaps = []string{
+	"skupper-site",
+	"skupper-services",
+	"skupper-internal",
+	"skupper-network-status",
+	"skupper-sasl-config",
+}
+
+// A frame2.FailureHook that saves the state of all namespaces on the
+// TestBase when a step fails: pod status, container logs (including those
+// of the previous instances of restarted containers), events, deployments,
+// services, configmaps and Skupper resources.
+//
+// They are saved under the failing runner's artifact directory (see
+// frame2.Run.ArtifactDir), on cluster-<runner id>/<cluster>/<namespace>,
+// with the cluster-scoped Skupper resources on
+// cluster-<runner id>/<cluster>/skupper; if there is no artifact directory,
+// nothing is saved.
+//
+// Register it with frame2.Run.AddFailureHook
+type ClusterDumper struct {
+	TestBase *TestBase
+
+	// The number of lines kept from the end of each container log; all of
+	// them if zero
+	TailLines int64
+
+	// The resources to be saved with the dynamic client; if nil,
+	// SkupperResources
+	Resources []schema.GroupVersionResource
+
+	// The cluster-scoped resources to be saved with the dynamic client; if
+	// nil, SkupperClusterResources
+	ClusterResources []schema.GroupVersionResource
+
+	// How long the whole dump may take; two minutes if zero.  The dump does
+	// not use the test's context, as it may be the deadline that failed the
+	// test
+	Timeout time.Duration
+}
+
+func (c ClusterDumper) FailureHook(runner *frame2.Run, failure error) error {
+	dir := runner.ArtifactDir()
+	if dir == "" {
+		runner.LogLevelf(frame2.LevelWarn, "cluster state not saved: no artifact directory (see frame2.ENV_ARTIFACT_DIR)")
+		return nil
+	}
+	dir = filepath.Join(dir, "cluster-"+runner.GetId())
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	clusters := map[string]bool{}
+	for _, ns := range c.TestBase.GetAllNamespaces() {
+		cluster := ns.GetKubeConfig()
+		if !clusters[cluster.GetName()] {
+			clusters[cluster.GetName()] = true
+			if err := c.DumpCluster(ctx, cluster.GetDynamicClient(), filepath.Join(dir, cluster.GetName())); err != nil {
+				errs = append(errs, fmt.Errorf("cluster %q: %w", cluster.GetName(), err))
+			}
+		}
+		nsDir := filepath.Join(dir, cluster.GetName(), ns.GetNamespaceName())
+		if err := c.DumpNamespace(ctx, ns.KubeClient(), cluster.GetDynamicClient(), ns.GetNamespaceName(), nsDir); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %q: %w", ns.GetNamespaceName(), err))
+		}
+	}
+	runner.LogLevelf(frame2.LevelInfo, "cluster state saved to %q", dir)
+	return errors.Join(errs...)
+}
+
+// Saves the state of a single namespace into dir, as YAML files, with
+// the container logs under dir/logs.  Failures on individual items do not
+// stop the dump; they are all returned at the end.
+//
+// If dyn is nil, the Skupper resources are not saved
+func (c ClusterDumper) DumpNamespace(ctx context.Context, kube kubernetes.Interface, dyn dynamic.Interface, namespace, dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "logs"), 0755); err != nil {
+		return fmt.Errorf("failed to create the dump directory: %w", err)
+	}
+	var errs []error
+	save := func(name string, list any, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %w", name, err))
+			return
+		}
+		if err := writeYAML(filepath.Join(dir, name+".yaml"), list); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	opts := metav1.ListOptions{}
+	pods, err := kube.CoreV1().Pods(namespace).List(ctx, opts)
+	save("pods", pods, err)
+	events, err := kube.CoreV1().Events(namespace).List(ctx, opts)
+	save("events", events, err)
+	deployments, err := kube.AppsV1().Deployments(namespace).List(ctx, opts)
+	save("deployments", deployments, err)
+	services, err := kube.CoreV1().Services(namespace).List(ctx, opts)
+	save("services", services, err)
+	configMaps, err := kube.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+	save("configmaps", configMaps, err)
+	if configMaps != nil {
+		for _, cm := range configMaps.Items {
+			if slices.Contains(SkupperConfigMaps, cm.Name) {
+				save(filepath.Join("skupper", "configmap-"+cm.Name), cm, nil)
+			}
+		}
+	}
+
+	if pods != nil {
+		for _, pod := range pods.Items {
+			errs = append(errs, c.dumpPodLogs(ctx, kube, pod, filepath.Join(dir, "logs")))
+		}
+	}
+
+	if dyn != nil {
+		resources := c.Resources
+		if resources == nil {
+			resources = SkupperResources
+		}
+		for _, gvr := range resources {
+			list, err := dyn.Resource(gvr).Namespace(namespace).List(ctx, opts)
+			if k8serrors.IsNotFound(err) {
+				// The CRD is not installed
+				continue
+			}
+			save(filepath.Join("skupper", gvr.Resource), list, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Saves the cluster-scoped resources into dir/skupper, as YAML files.  Those
+// whose CRDs are not installed are skipped
+func (c ClusterDumper) DumpCluster(ctx context.Context, dyn dynamic.Interface, dir string) error {
+	if dyn == nil {
+		return nil
+	}
+	resources := c.ClusterResources
+	if resources == nil {
+		resources = SkupperClusterResources
+	}
+	var errs []error
+	for _, gvr := range resources {
+		list, err := dyn.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list %s: %w", gvr.Resource, err))
+			continue
+		}
+		if err := writeYAML(filepath.Join(dir, "skupper", gvr.Resource+".yaml"), list); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Saves the logs of all containers of the pod, including init containers.
+// For containers that were restarted, the logs of the previous instance
+// are saved as well
+func (c ClusterDumper) dumpPodLogs(ctx context.Context, kube kubernetes.Interface, pod corev1.Pod, dir string) error {
+	restarts := map[string]int32{}
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			restarts[status.Name] = status.RestartCount
+		}
+	}
+	var containers []corev1.Container
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+
+	var errs []error
+	for _, container := range containers {
+		for _, previous := range []bool{false, true} {
+			if previous && restarts[container.Name] == 0 {
+				continue
+			}
+			name := fmt.Sprintf("%s_%s.log", pod.Name, container.Name)
+			if previous {
+				name = fmt.Sprintf("%s_%s.previous.log", pod.Name, container.Name)
+			}
+			logOpts := &corev1.PodLogOptions{
+				Container: container.Name,
+				Previous:  previous,
+			}
+			if c.TailLines > 0 {
+				tail := c.TailLines
+				logOpts.TailLines = &tail
+			}
+			if err := saveStream(ctx, kube.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOpts).Stream, filepath.Join(dir, name)); err != nil {
+				errs = append(errs, fmt.Errorf("failed to get the logs for %s: %w", strings.TrimSuffix(name, ".log"), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func saveStream(ctx context.Context, stream func(context.Context) (io.ReadCloser, error), path string) error {
+	r, err := stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func writeYAML(path string, obj any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content, err := yaml.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %q: %w", filepath.Base(path), err)
+	}
+	return os.WriteFile(path, content, 0644)
+}
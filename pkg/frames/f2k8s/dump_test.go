@@ -0,0 +1,114 @@
+package f2k8s
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterDumper(t *testing.T) {
+	ns := "dump-test"
+	kube := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "router", Namespace: ns},
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "init"}},
+				Containers:     []corev1.Container{{Name: "router"}, {Name: "controller"}},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "router", RestartCount: 2},
+					{Name: "controller"},
+				},
+			},
+		},
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{Name: "router.1", Namespace: ns},
+			Reason:     "BackOff",
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: ns}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "skupper-site", Namespace: ns}},
+		// On another namespace; not dumped
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "other"}},
+	)
+
+	sites := schema.GroupVersionResource{Group: "skupper.io", Version: "v2alpha1", Resource: "sites"}
+	site := &unstructured.Unstructured{}
+	site.SetAPIVersion("skupper.io/v2alpha1")
+	site.SetKind("Site")
+	site.SetName("west")
+	site.SetNamespace(ns)
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{sites: "SiteList"},
+		site,
+	)
+
+	dir := t.TempDir()
+	dumper := ClusterDumper{Resources: []schema.GroupVersionResource{sites}}
+	assert.Assert(t, dumper.DumpNamespace(context.Background(), kube, dyn, ns, dir))
+
+	read := func(name string) string {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		assert.Assert(t, err, name)
+		return string(content)
+	}
+	assert.Assert(t, strings.Contains(read("pods.yaml"), "phase: Running"))
+	assert.Assert(t, strings.Contains(read("events.yaml"), "reason: BackOff"))
+	assert.Assert(t, strings.Contains(read("services.yaml"), "name: backend"))
+	configMaps := read("configmaps.yaml")
+	assert.Assert(t, strings.Contains(configMaps, "name: skupper-site"))
+	assert.Assert(t, !strings.Contains(configMaps, "name: other"))
+	assert.Assert(t, strings.Contains(read("deployments.yaml"), "items:"))
+	assert.Assert(t, strings.Contains(read("skupper/sites.yaml"), "name: west"))
+	assert.Assert(t, strings.Contains(read("skupper/configmap-skupper-site.yaml"), "name: skupper-site"))
+
+	// The fake clientset returns "fake logs" for any container
+	for _, name := range []string{"router_init.log", "router_router.log", "router_router.previous.log", "router_controller.log"} {
+		assert.Equal(t, read(filepath.Join("logs", name)), "fake logs")
+	}
+	// The controller never restarted
+	_, err := os.Stat(filepath.Join(dir, "logs", "router_controller.previous.log"))
+	assert.Assert(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestClusterDumperClusterResources(t *testing.T) {
+	policies := schema.GroupVersionResource{Group: "skupper.io", Version: "v1alpha1", Resource: "skupperclusterpolicies"}
+	policy := &unstructured.Unstructured{}
+	policy.SetAPIVersion("skupper.io/v1alpha1")
+	policy.SetKind("SkupperClusterPolicy")
+	policy.SetName("allow-all")
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{policies: "SkupperClusterPolicyList"},
+		policy,
+	)
+
+	dir := t.TempDir()
+	assert.Assert(t, ClusterDumper{}.DumpCluster(context.Background(), dyn, dir))
+	content, err := os.ReadFile(filepath.Join(dir, "skupper", "skupperclusterpolicies.yaml"))
+	assert.Assert(t, err)
+	assert.Assert(t, strings.Contains(string(content), "name: allow-all"), string(content))
+}
+
+func TestClusterDumperNoArtifacts(t *testing.T) {
+	// Without an artifact directory, nothing is done, not even looking at
+	// the TestBase
+	t.Setenv(frame2.ENV_ARTIFACT_DIR, "")
+	r := &frame2.Run{T: t}
+	assert.Assert(t, ClusterDumper{}.FailureHook(r, errors.New("failed")))
+}
@@ -0,0 +1,7 @@
+package disruptor
+
+import frame2 "github.com/hash-d/frame2/pkg"
+
+func init() {
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &DeploymentConfigBlindly{} })
+}
@@ -2,12 +2,10 @@ package f2skupper1
 
 import (
 	"log"
-	"os"
 	"regexp"
 	"sort"
 	"strconv"
 
-	frame2 "github.com/hash-d/frame2/pkg"
 	"github.com/hash-d/frame2/pkg/frames/f2k8s"
 )
 
@@ -58,7 +56,7 @@ func (s SkupperVersionerDefault) GetSkupperVersion() string {
 	if s.SkupperVersion != "" {
 		return s.SkupperVersion
 	}
-	return os.Getenv(frame2.ENV_VERSION)
+	return SettingVersion.Value()
 }
 
 // Given a list of versions, WhichSkupperVersion will return the one that
@@ -88,7 +86,7 @@ func (s SkupperVersionerDefault) GetSkupperVersion() string {
 func (s SkupperVersionerDefault) WhichSkupperVersion(candidates []string) string {
 
 	version := s.SkupperVersion
-	envVersion := os.Getenv(frame2.ENV_VERSION)
+	envVersion := SettingVersion.Value()
 	if version == "" && envVersion != "" {
 		// version was not explicitly set elsewhere, and there is a SKUPPER_TEST_VERSION
 		// configuration on the environment, so we use it.
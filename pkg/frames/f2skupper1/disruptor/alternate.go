@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/hash-d/frame2/pkg/frames/f2skupper1"
 	"log"
-	"os"
 
 	frame2 "github.com/hash-d/frame2/pkg"
 )
@@ -96,11 +95,11 @@ func (a *AlternateSkupper) Inspect(step *frame2.Step, phase *frame2.Phase) {
 
 			useNew := a.NewOnInstall != useAlternate
 			if useNew {
-				version := os.Getenv(frame2.ENV_VERSION)
+				version := f2skupper1.SettingVersion.Value()
 				log.Printf("AlternateSkupper disruptor resetting version to %q for %T", version, frame)
 				frame.SetSkupperVersion(version)
 			} else {
-				version := os.Getenv(frame2.ENV_OLD_VERSION)
+				version := f2skupper1.SettingOldVersion.Value()
 				log.Printf("AlternateSkupper disruptor updating version to %q for %T", version, frame)
 				frame.SetSkupperVersion(version)
 			}
@@ -0,0 +1,18 @@
+package disruptor
+
+import frame2 "github.com/hash-d/frame2/pkg"
+
+func init() {
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &AlternateSkupper{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &NoConsole{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &ConsoleOnAll{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &NoFlowCollector{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &FlowCollectorOnAll{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &ConsoleAuth{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &EdgeOnPrivate{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &SkipManifestCheck{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &NoHttp{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &EnableTls{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &UpgradeAndFinalize{} })
+	frame2.RegisterDisruptor(func() frame2.Disruptor { return &MixedVersionVan{} })
+}
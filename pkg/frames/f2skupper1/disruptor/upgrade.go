@@ -3,13 +3,13 @@ package disruptor
 import (
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/hash-d/frame2/pkg/frames/f2skupper1"
 
 	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/env"
 	"github.com/hash-d/frame2/pkg/frames/f2k8s"
 )
 
@@ -38,28 +38,44 @@ const (
 	VAR_EMPTY_VALUE = "frame2.empty"
 )
 
+var settingUpgradeStrategy = env.Register(env.Setting{
+	Name:        frame2.ENV_UPGRADE_STRATEGY,
+	Default:     string(UPGRADE_STRATEGY_CREATION),
+	Description: "the order of the sites on the upgrade disruptors: CREATION, PUB_FIRST or PRV_FIRST, optionally followed by :INVERSE",
+	Package:     "f2skupper1/disruptor",
+	Check: func(value string) error {
+		_, _, err := parseUpgradeStrategy(value)
+		return err
+	},
+})
+
 // Returns the Upgrade strategy configured in the environment
 func getUpgradeStrategy() (TestUpgradeStrategy, bool) {
-	var invert bool
-	var strategy TestUpgradeStrategy
-
-	envValue := os.Getenv(frame2.ENV_UPGRADE_STRATEGY)
+	strategy, invert, err := parseUpgradeStrategy(settingUpgradeStrategy.Value())
+	if err != nil {
+		panic(err.Error())
+	}
+	return strategy, invert
+}
 
-	s := strings.SplitN(envValue, ":", 2)
+func parseUpgradeStrategy(value string) (strategy TestUpgradeStrategy, invert bool, err error) {
+	s := strings.SplitN(value, ":", 2)
 	strategy = TestUpgradeStrategy(s[0])
-	if strategy == "" {
+	switch strategy {
+	case "":
 		strategy = UPGRADE_STRATEGY_CREATION
+	case UPGRADE_STRATEGY_CREATION, UPGRADE_STRATEGY_PUB_FIRST, UPGRADE_STRATEGY_PRV_FIRST:
+	default:
+		return "", false, fmt.Errorf("invalid upgrade strategy: %v", strategy)
 	}
 	if len(s) > 1 {
 		if s[1] == string(UPGRADE_STRATEGY_INVERSE) {
 			invert = true
 		} else {
-			panic(fmt.Sprintf("invalid option to SKUPPER_TEST_UPGRADE_STRATEGY: %v", s[1]))
+			return "", false, fmt.Errorf("invalid option to SKUPPER_TEST_UPGRADE_STRATEGY: %v", s[1])
 		}
 	}
-
-	return strategy, invert
-
+	return strategy, invert, nil
 }
 
 // Return the public and private contexts in different slices, but keeping
@@ -202,11 +218,11 @@ func (u *UpgradeAndFinalize) Inspect(step *frame2.Step, phase *frame2.Phase) {
 		}
 		if frame, ok := frame.(f2skupper1.SkupperVersioner); ok {
 			if !u.useNew {
-				version := os.Getenv(frame2.ENV_OLD_VERSION)
+				version := f2skupper1.SettingOldVersion.Value()
 				log.Printf("UpgradeAndFinalize disruptor updating version to %q for %T", version, frame)
 				frame.SetSkupperVersion(version)
 			} else {
-				version := os.Getenv(frame2.ENV_VERSION)
+				version := f2skupper1.SettingVersion.Value()
 				log.Printf("UpgradeAndFinalize disruptor resetting version to %q for %T", version, frame)
 				frame.SetSkupperVersion(version)
 			}
@@ -238,22 +254,22 @@ func setCliPathCurrentEnv(action f2skupper1.SkupperCliPathSetter) {
 // SKUPPER_TEST_OLD_BIN, and sets the execution environment to add or overwrite
 // any Skupper IMAGE variables with their SKUPPER_TEST_OLD settings
 func setCliPathOldEnv(action f2skupper1.SkupperCliPathSetter) {
-	path := os.Getenv(frame2.ENV_OLD_BIN)
+	path := f2skupper1.SettingOldBin.Value()
 	if path == "" {
 		panic("Upgrade disruptor requested, but no SKUPPER_TEST_OLD_BIN config")
 	}
 
 	// For those SKUPPER_TEST_OLD image variables that are set, we change them
 	// on the environment for the called command
-	var env []string
+	var environ []string
 	for oldEnvKey, envKey := range frame2.EnvOldMap {
-		// Do not change to os.GetEnv: we want the ability to unset a variable
+		// Do not change to Value(): we want the ability to unset a variable
 		// for the old version
-		if image, ok := os.LookupEnv(oldEnvKey); ok {
+		if image, ok := env.Lookup(oldEnvKey); ok {
 			if image == VAR_EMPTY_VALUE {
 				image = ""
 			}
-			env = append(env, fmt.Sprintf("%s=%s", envKey, image))
+			environ = append(environ, fmt.Sprintf("%s=%s", envKey, image))
 		}
 
 	}
@@ -262,10 +278,10 @@ func setCliPathOldEnv(action f2skupper1.SkupperCliPathSetter) {
 		"Action %T updated with path %q and additional environment %+v",
 		action,
 		path,
-		env,
+		environ,
 	)
 
-	action.SetSkupperCliPath(path, env)
+	action.SetSkupperCliPath(path, environ)
 }
 
 // Right after setup is complete, update part of the VAN, and
@@ -343,11 +359,11 @@ func (m *MixedVersionVan) Inspect(step *frame2.Step, phase *frame2.Phase) {
 		}
 		if action, ok := frame.(f2skupper1.SkupperVersioner); ok {
 			if !m.useNew {
-				version := os.Getenv(frame2.ENV_OLD_VERSION)
+				version := f2skupper1.SettingOldVersion.Value()
 				log.Printf("MixedVersionVan disruptor updating version to %q for %T", version, action)
 				action.SetSkupperVersion(version)
 			} else {
-				version := os.Getenv(frame2.ENV_VERSION)
+				version := f2skupper1.SettingVersion.Value()
 				log.Printf("MixedVersionVan disruptor resetting version to %q for %T", version, action)
 				action.SetSkupperVersion(version)
 			}
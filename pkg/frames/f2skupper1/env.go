@@ -0,0 +1,41 @@
+package f2skupper1
+
+import (
+	"fmt"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/env"
+)
+
+// The settings for the Skupper versions and binaries, used by the
+// SkupperVersioner implementations and by the upgrade disruptors
+var (
+	SettingVersion = env.Register(env.Setting{
+		Name:        frame2.ENV_VERSION,
+		Description: "the expected version of the skupper binary found on the PATH",
+		Package:     "f2skupper1",
+	})
+	SettingOldVersion = env.Register(env.Setting{
+		Name:        frame2.ENV_OLD_VERSION,
+		Description: "the version of the binary on SKUPPER_TEST_OLD_BIN, such as 1.2 or 1.4.0-rc3",
+		Package:     "f2skupper1",
+	})
+	SettingOldBin = env.Register(env.Setting{
+		Name:        frame2.ENV_OLD_BIN,
+		Description: "the path to the skupper binary of the old version, for the upgrade disruptors",
+		Package:     "f2skupper1",
+	})
+)
+
+// The SKUPPER_TEST_OLD image variables on frame2.EnvOldMap, which are
+// looked up with env.Lookup; their registration is only for validation
+// and for listing them
+func init() {
+	for oldKey, key := range frame2.EnvOldMap {
+		env.Register(env.Setting{
+			Name:        oldKey,
+			Description: fmt.Sprintf("the value of %s for the old skupper version; set it to frame2.empty for an empty value", key),
+			Package:     "f2skupper1",
+		})
+	}
+}
@@ -120,7 +120,8 @@ func TestSkupperInstallEffects(t *testing.T) {
 			"defaults": {
 				// Do not do combos with this one, as its validations might conflict
 				// with other CauseEffect items
-				Doc: "Confirm a plain skupper install is successful and with expected default values",
+				Doc:        "Confirm a plain skupper install is successful and with expected default values",
+				Standalone: true,
 				Patch: f2skupper1.CliSkupperInstall{
 					EnableConsole:       false,
 					EnableFlowCollector: false,
@@ -68,12 +68,14 @@ func (s Status) Execute() error {
 	stdoutNot := []regexp.Regexp{}
 	reStdout := []regexp.Regexp{}
 
+	// Checks that the version does not support; if the others pass, the
+	// result is a skip
+	var unsupported string
+
 	version := s.SkupperVersionerDefault.WhichSkupperVersion([]string{"1.4", "1.5"})
 	if s.Verbose {
 		if version == "1.4" {
-			// TODO: in the future, return an error that means 'test skipped due to version'
-			// -v was added in 1.5
-			return nil
+			return frame2.Skip("skupper status -v was added in 1.5")
 		}
 		if s.CheckStatus {
 			if s.Enabled {
@@ -120,8 +122,7 @@ func (s Status) Execute() error {
 			if version == "1.4" {
 				stdout = append(stdout, fmt.Sprintf("in %s mode.", s.Mode))
 			} else {
-				// do nothing
-				// TODO: in the future, return an error that means 'test skipped due to version'
+				unsupported = "the mode is not shown by non-verbose skupper status after 1.4"
 			}
 		}
 		if s.CheckPolicies {
@@ -181,7 +182,13 @@ func (s Status) Execute() error {
 		},
 	}
 
-	return phase.Run()
+	if err := phase.Run(); err != nil {
+		return err
+	}
+	if unsupported != "" {
+		return frame2.Skip("%s", unsupported)
+	}
+	return nil
 }
 
 func (s Status) Validate() error {
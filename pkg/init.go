@@ -4,7 +4,6 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"log"
-	"os"
 
 	"github.com/google/uuid"
 )
@@ -13,7 +12,7 @@ import (
 func init() {
 
 	// By default, we use the environment provided TEST_ID
-	stringId := os.Getenv("TEST_ID")
+	stringId := settingTestId.Value()
 	if stringId == "" {
 		// If that was not provided, we create a new one, but each
 		// package/binary will have its own, on a single `go test` invokation
@@ -0,0 +1,175 @@
+package frame2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The interactive mode prompts the user for an action whenever a named
+// step fails, which helps debugging long tests locally: the environment
+// can be inspected before the teardown destroys it, or a failure can be
+// ignored to check the steps after it.
+//
+// It is enabled by ENV_INTERACTIVE, the -frame2-interactive flag (see
+// Flag) or SetInteractive.  Prompts are serialized, so parallel steps
+// take turns.
+var (
+	interactiveLock sync.Mutex
+	interactiveIn   *bufio.Reader
+	interactiveOut  io.Writer
+	interactiveOnce sync.Once
+)
+
+// What to do about a failed step, as answered by the user
+type interactiveAction int
+
+const (
+	// No interaction: the failure stands, as it would without the
+	// interactive mode
+	actionFail interactiveAction = iota
+	// Ignore the error, and go on with the test
+	actionContinue
+	// Fail, skipping the remaining steps and the teardowns
+	actionKill
+	// Fail, skipping the remaining steps but running the teardowns
+	actionFinish
+)
+
+// Enables the interactive mode, reading the answers from in and writing
+// the prompts to out.  This allows for scripted answers, such as on
+// tests.  A nil in disables the interactive mode.
+//
+// It takes precedence over ENV_INTERACTIVE
+func SetInteractive(in io.Reader, out io.Writer) {
+	interactiveOnce.Do(func() {})
+	interactiveLock.Lock()
+	defer interactiveLock.Unlock()
+	if in == nil {
+		interactiveIn = nil
+		interactiveOut = nil
+		return
+	}
+	if out == nil {
+		out = io.Discard
+	}
+	interactiveIn = bufio.NewReader(in)
+	interactiveOut = out
+}
+
+// Enables the interactive mode on the given terminal, such as /dev/tty,
+// which is used for both the prompts and the answers
+func openInteractive(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %q for the interactive mode: %w", path, err)
+	}
+	SetInteractive(f, f)
+	return nil
+}
+
+func loadInteractiveEnv() {
+	path := settingInteractive.Value()
+	if path == "" {
+		return
+	}
+	if err := openInteractive(path); err != nil {
+		log.Printf("[R] %v", err)
+	}
+}
+
+// Asks the user what to do about the failed step.  If the interactive
+// mode is not enabled, or the input is exhausted, the answer is actionFail
+func promptFailure(t *testing.T, id string, err error) interactiveAction {
+	interactiveOnce.Do(loadInteractiveEnv)
+	interactiveLock.Lock()
+	defer interactiveLock.Unlock()
+	if interactiveIn == nil {
+		return actionFail
+	}
+
+	out := interactiveOut
+	for {
+		fmt.Fprintf(out, "\n[frame2] %v %q failed: %v\n", id, t.Name(), err)
+		fmt.Fprintf(out, "[frame2] (c)ontinue ignoring the error, (h)old, (k)ill with no teardown, (f)inish running the teardown, or Enter to fail: ")
+		answer, readErr := readAnswer()
+		switch {
+		case answer == "":
+			if readErr != nil && readErr != io.EOF {
+				log.Printf("[R] failed to read the interactive answer: %v", readErr)
+			}
+			return actionFail
+		case strings.HasPrefix("continue", answer):
+			return actionContinue
+		case strings.HasPrefix("kill", answer):
+			return actionKill
+		case strings.HasPrefix("finish", answer):
+			return actionFinish
+		case strings.HasPrefix("hold", answer):
+			if deadline, ok := t.Deadline(); ok {
+				fmt.Fprintf(out, "[frame2] Holding; the test deadline is in %v (%v)\n", time.Until(deadline).Round(time.Second), deadline.Format(time.TimeOnly))
+			} else {
+				fmt.Fprintf(out, "[frame2] Holding; the test has no deadline\n")
+			}
+			fmt.Fprintf(out, "[frame2] Press Enter to return to the prompt")
+			if _, readErr := readAnswer(); readErr != nil {
+				return actionFail
+			}
+		default:
+			fmt.Fprintf(out, "[frame2] Unknown option %q\n", answer)
+		}
+	}
+}
+
+// Reads a line from the interactive input, lowercased and trimmed
+func readAnswer() (string, error) {
+	line, err := interactiveIn.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(line)), err
+}
+
+// Records the action on the root runner: whether the remaining steps and
+// the teardowns should be skipped
+func (r *Run) setInteractiveAction(action interactiveAction) {
+	interactiveLock.Lock()
+	defer interactiveLock.Unlock()
+	root := r.getRoot()
+	switch action {
+	case actionKill:
+		root.interactiveAbort = true
+		root.interactiveKill = true
+	case actionFinish:
+		root.interactiveAbort = true
+	}
+}
+
+// Whether the user chose to finish or kill the test; the remaining steps
+// are not run
+func (r *Run) interactiveAborted() bool {
+	interactiveLock.Lock()
+	defer interactiveLock.Unlock()
+	return r.getRoot().interactiveAbort
+}
+
+// Whether the user chose to kill the test; the teardowns are not run
+func (r *Run) interactiveKilled() bool {
+	interactiveLock.Lock()
+	defer interactiveLock.Unlock()
+	return r.getRoot().interactiveKill
+}
+
+// Whether the runner is part of a teardown.  Failures there are not
+// prompted for, and teardowns are not aborted
+func (r *Run) inTeardown() bool {
+	for ; r != nil; r = r.parent {
+		if r.kind == TearDownRunner {
+			return true
+		}
+	}
+	return false
+}
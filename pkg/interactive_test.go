@@ -0,0 +1,110 @@
+package frame2
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Counts its executions
+type interactiveCounter struct {
+	count *int
+}
+
+func (c interactiveCounter) Execute() error {
+	*c.count++
+	return nil
+}
+
+type interactiveFail struct{}
+
+func (interactiveFail) Execute() error {
+	return errors.New("failing on purpose")
+}
+
+func TestInteractivePrompt(t *testing.T) {
+	defer SetInteractive(nil, nil)
+	failure := errors.New("some failure")
+
+	for _, item := range []struct {
+		input  string
+		action interactiveAction
+	}{
+		{"c\n", actionContinue},
+		{"Kill\n", actionKill},
+		{"finish\n", actionFinish},
+		{"\n", actionFail},
+		{"", actionFail},
+		{"unknown\nf\n", actionFinish},
+		{"h\n\ncontinue\n", actionContinue},
+	} {
+		var out bytes.Buffer
+		SetInteractive(strings.NewReader(item.input), &out)
+		assert.Equal(t, promptFailure(t, "R0.s0", failure), item.action, "input %q", item.input)
+		assert.Assert(t, strings.Contains(out.String(), "some failure"), out.String())
+		if strings.HasPrefix(item.input, "h") {
+			assert.Assert(t, strings.Contains(out.String(), "Holding"), out.String())
+		}
+	}
+
+	// Without the interactive mode, failures just stand
+	SetInteractive(nil, nil)
+	assert.Equal(t, promptFailure(t, "R0.s0", failure), actionFail)
+}
+
+func TestInteractiveActions(t *testing.T) {
+	for _, item := range []struct {
+		action    interactiveAction
+		teardowns int
+	}{
+		{actionFinish, 1},
+		{actionKill, 0},
+	} {
+		var steps, teardowns int
+		// No testing.T, so the teardown runs at the end of Phase.Run
+		runner := &Run{}
+		runner.setInteractiveAction(item.action)
+		p := Phase{
+			Runner: runner,
+			MainSteps: []Step{
+				{
+					Modify: interactiveCounter{&steps},
+				},
+			},
+			Teardown: []Step{
+				{
+					Modify: interactiveCounter{&teardowns},
+				},
+			},
+		}
+		assert.Assert(t, p.Run() != nil, "the steps should not be run after %v", item.action)
+		assert.Equal(t, steps, 0)
+		assert.Equal(t, teardowns, item.teardowns)
+	}
+}
+
+func TestInteractiveContinue(t *testing.T) {
+	var out bytes.Buffer
+	SetInteractive(strings.NewReader("c\n"), &out)
+	defer SetInteractive(nil, nil)
+
+	var steps int
+	p := Phase{
+		Runner: &Run{T: t},
+		MainSteps: []Step{
+			{
+				Name:   "failing",
+				Modify: interactiveFail{},
+			}, {
+				Modify: interactiveCounter{&steps},
+			},
+		},
+	}
+	// The failure on the named step is ignored, so this test does not fail
+	assert.Assert(t, p.Run())
+	assert.Equal(t, steps, 1)
+	assert.Assert(t, strings.Contains(out.String(), "TestInteractiveContinue/failing"), out.String())
+}
@@ -1,13 +1,20 @@
 package frame2
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 type FrameLogger interface {
-	// passes its parameters to a log.Logger's Printf.  Either
-	// the one returned by GetLogger, or the default logger if
-	// that call returns nil
+	// Logs at LevelInfo, to the log.Logger returned by GetLogger, or
+	// to the package's logger if that call returns nil (see Log.Logf)
 	Printf(format string, v ...any)
 	GetLogger() *log.Logger
 	SetLogger(logger *log.Logger)
@@ -16,20 +23,21 @@ type FrameLogger interface {
 	OrSetLogger(logger *log.Logger)
 }
 
-// Unconfigured, this simply forwards any Printf calls to the
-// default logger.
+// Unconfigured, this sends any Printf calls to the package's logger (see
+// Logger), at LevelInfo, so they are subject to ENV_LOG_LEVEL.
 //
 // If a log.Logger is set, use it instead.
 type Log struct {
 	Logger *log.Logger
+
+	// Set by the framework, for the leveled logs; see linkLog
+	runner *Run
+	dealer *DefaultRunDealer
 }
 
+// The same as Logf
 func (l *Log) Printf(format string, v ...any) {
-	if l == nil || l.Logger == nil {
-		log.Printf(format, v...)
-	} else {
-		l.Logger.Printf(format, v...)
-	}
+	l.logAt(LevelInfo, format, v)
 }
 
 func (l *Log) GetLogger() *log.Logger {
@@ -69,3 +77,396 @@ func OrSetLogger(x FrameLogger, logger *log.Logger) {
 		(x).OrSetLogger(logger)
 	}
 }
+
+// The levels for the leveled logs; they are the same as slog's
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+)
+
+// Logs at LevelInfo, using the package's logger (see Logger).  Frames should
+// use the methods of their embedded Log instead, which add the runner ID
+func Logf(format string, v ...any) {
+	logAt(nil, nil, LevelInfo, 1, format, v)
+}
+
+// Logs at LevelDebug; see Logf
+func LogVerbosef(format string, v ...any) {
+	logAt(nil, nil, LevelDebug, 1, format, v)
+}
+
+// Logs at the given level; see Logf
+func LogLevelf(level slog.Level, format string, v ...any) {
+	logAt(nil, nil, level, 1, format, v)
+}
+
+// Logs at LevelInfo, adding the frame's runner ID and depth.  If a Logger
+// is set on l, the output goes to it; otherwise, to the package's logger
+// (see Logger)
+func (l *Log) Logf(format string, v ...any) {
+	l.logAt(LevelInfo, format, v)
+}
+
+// Logs at LevelDebug; see Log.Logf
+func (l *Log) LogVerbosef(format string, v ...any) {
+	l.logAt(LevelDebug, format, v)
+}
+
+// Logs at the given level; see Log.Logf
+func (l *Log) LogLevelf(level slog.Level, format string, v ...any) {
+	l.logAt(level, format, v)
+}
+
+func (l *Log) logAt(level slog.Level, format string, v []any) {
+	var logger *log.Logger
+	var runner *Run
+	if l != nil {
+		logger = l.Logger
+		runner = l.getRunner()
+	}
+	logAt(logger, runner, level, 2, format, v)
+}
+
+// The runner of the frame the Log is embedded on: that of its
+// DefaultRunDealer, if any, or otherwise the runner of the step that
+// holds the frame.  Both are set by the framework; see linkLog
+func (l *Log) getRunner() *Run {
+	logLinkLock.Lock()
+	defer logLinkLock.Unlock()
+	if l.dealer != nil && l.dealer.Runner != nil {
+		return l.dealer.Runner
+	}
+	return l.runner
+}
+
+// Logs at the given level, with the runner's ID and depth, like the frames'
+// Log.LogLevelf; the line also goes to the runner's artifact logs.  For code
+// that gets a runner but has no frame Log, such as the failure hooks
+func (r *Run) LogLevelf(level slog.Level, format string, v ...any) {
+	logAt(nil, r, level, 1, format, v)
+}
+
+// Logs the runner's own messages, such as the "[R]" lines, at the given
+// level.  Their format already carries the runner ID, when relevant, so it
+// is not added again.  Like the frames' logs, they also go to the runner's
+// artifact logs
+func (r *Run) logf(level slog.Level, format string, v ...any) {
+	h, pc, ok := enabledAt(nil, level, 1)
+	if !ok {
+		return
+	}
+	msg := fmt.Sprintf(format, v...)
+	if r != nil {
+		r.writeArtifactLog(fmt.Sprintf("[%v] %s", level, msg))
+	}
+	_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), level, msg, pc))
+}
+
+// Sends the message to the handler, with the runner's ID and depth, if
+// any; the line also goes to the runner's artifact logs.  If logger is
+// given, the output goes to it, on the default format, instead of the
+// package's handler.
+//
+// skip is the number of frame2 functions between the caller and this
+// one, so the record gets the caller's PC (for the per-package levels)
+func logAt(logger *log.Logger, runner *Run, level slog.Level, skip int, format string, v []any) {
+	h, pc, ok := enabledAt(logger, level, skip+1)
+	if !ok {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, v...), pc)
+	if runner != nil {
+		r.AddAttrs(slog.String("runner", runner.GetId()), slog.Int("depth", runner.depth()))
+		runner.writeArtifactLog(fmt.Sprintf("[%v] %v %s", level, runner.GetId(), r.Message))
+	}
+	_ = h.Handle(context.Background(), r)
+}
+
+// Applies the levels for the package of the caller (skip frame2 functions
+// above the caller of this one).  If the level is enabled for it, returns
+// the handler to be used after the levels, and the caller's PC for the
+// record.  The handler is that of the package, or one that writes to
+// logger, if given.
+//
+// The check is done here, and not left to levelHandler, so that the lines
+// dropped by the levels do not reach the artifact logs either
+func enabledAt(logger *log.Logger, level slog.Level, skip int) (slog.Handler, uintptr, bool) {
+	h := packageHandler()
+	if logger != nil {
+		h.next = textHandler{logger: logger}
+	}
+	if !h.Enabled(context.Background(), level) {
+		return nil, 0, false
+	}
+	// Skips runtime.Callers and this function, too
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:])
+	if level < h.levels.forPC(pcs[0]) {
+		return nil, 0, false
+	}
+	return h.next, pcs[0], true
+}
+
+var (
+	logLock    sync.Mutex
+	logHandler slog.Handler
+	logLevels  *packageLevels
+)
+
+// Sets the slog.Handler for the leveled logs, such as a slog.JSONHandler.
+// The per-package levels (see ENV_LOG_LEVEL) are still applied before it.
+// A nil handler restores the default, which writes through the standard
+// log package, as in
+//
+//	[INFO] R0.p0.s1 message key=value
+func SetLogHandler(h slog.Handler) {
+	logLock.Lock()
+	defer logLock.Unlock()
+	logHandler = h
+}
+
+// Sets the log levels, overriding ENV_LOG_LEVEL; see it for the format.
+// An empty spec goes back to the levels from ENV_LOG_LEVEL
+func SetLogLevel(spec string) error {
+	var levels *packageLevels
+	if spec != "" {
+		var err error
+		if levels, err = parseLogLevels(spec); err != nil {
+			return err
+		}
+	}
+	logLock.Lock()
+	defer logLock.Unlock()
+	logLevels = levels
+	return nil
+}
+
+// Returns the leveled logger for the package, with the per-package levels
+// applied.  Use Run.Logger for one that adds the runner ID
+func Logger() *slog.Logger {
+	return slog.New(packageHandler())
+}
+
+// The package's handler (see SetLogHandler), with the per-package levels
+func packageHandler() levelHandler {
+	levels := getLogLevels()
+	logLock.Lock()
+	next := logHandler
+	logLock.Unlock()
+	if next == nil {
+		next = textHandler{}
+	}
+	return levelHandler{next: next, levels: levels}
+}
+
+// Returns the leveled logger with the runner's ID and depth (the number of
+// runners above it on the tree) as attributes
+func (r *Run) Logger() *slog.Logger {
+	return Logger().With(slog.String("runner", r.GetId()), slog.Int("depth", r.depth()))
+}
+
+func getLogLevels() *packageLevels {
+	logLock.Lock()
+	defer logLock.Unlock()
+	if logLevels == nil {
+		levels, err := parseLogLevels(settingLogLevel.Value())
+		if err != nil {
+			log.Printf("[R] ignoring %s: %v", ENV_LOG_LEVEL, err)
+			levels, _ = parseLogLevels("")
+		}
+		logLevels = levels
+	}
+	return logLevels
+}
+
+// The minimum level for the logs, by package
+type packageLevels struct {
+	def      slog.Level
+	packages map[string]slog.Level
+	// The lowest of all levels, for a quick Enabled check
+	min slog.Level
+}
+
+func parseLogLevels(spec string) (*packageLevels, error) {
+	ret := &packageLevels{
+		def:      LevelInfo,
+		packages: map[string]slog.Level{},
+	}
+	if settingVerbose.Enabled() {
+		ret.def = LevelDebug
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		pkg, levelName, found := strings.Cut(item, "=")
+		if !found {
+			levelName = pkg
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(levelName)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", item, err)
+		}
+		if found {
+			ret.packages[pkg] = level
+		} else {
+			ret.def = level
+		}
+	}
+	ret.min = ret.def
+	for _, level := range ret.packages {
+		ret.min = min(ret.min, level)
+	}
+	return ret, nil
+}
+
+// The level for the package of the function at pc.  Packages are matched
+// by their full import path or its last element, such as
+// "github.com/hash-d/frame2/pkg/frames/f2k8s" or "f2k8s"
+func (p *packageLevels) forPC(pc uintptr) slog.Level {
+	if pc == 0 || len(p.packages) == 0 {
+		return p.def
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	// Function names are like path/to/pkg.(*Type).Method or path/to/pkg.Func
+	fn := frame.Function
+	slash := strings.LastIndex(fn, "/")
+	path := fn
+	if dot := strings.Index(fn[slash+1:], "."); dot >= 0 {
+		path = fn[:slash+1+dot]
+	}
+	if level, ok := p.packages[path]; ok {
+		return level
+	}
+	if level, ok := p.packages[path[slash+1:]]; ok {
+		return level
+	}
+	return p.def
+}
+
+// Applies the per-package levels before passing the records on
+type levelHandler struct {
+	next   slog.Handler
+	levels *packageLevels
+}
+
+func (h levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.min && h.next.Enabled(ctx, level)
+}
+
+func (h levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.levels.forPC(r.PC) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{next: h.next.WithAttrs(attrs), levels: h.levels}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{next: h.next.WithGroup(name), levels: h.levels}
+}
+
+// The default handler: writes through logger (or the standard logger, if
+// nil) as "[LEVEL] runner message key=value", with the message indented by
+// the runner's depth
+type textHandler struct {
+	logger *log.Logger
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h textHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h textHandler) Handle(_ context.Context, r slog.Record) error {
+	var runner string
+	var depth int64
+	var rest strings.Builder
+	add := func(a slog.Attr) bool {
+		switch a.Key {
+		case "runner":
+			runner = a.Value.String()
+		case "depth":
+			depth = a.Value.Int64()
+		default:
+			fmt.Fprintf(&rest, " %s%s=%v", h.group, a.Key, a.Value)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+
+	line := fmt.Sprintf("[%v] ", r.Level)
+	if runner != "" {
+		line += strings.Repeat(" ", int(depth)) + runner + " "
+	}
+	line += r.Message + rest.String()
+	if h.logger != nil {
+		h.logger.Print(line)
+	} else {
+		log.Print(line)
+	}
+	return nil
+}
+
+func (h textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	// Attributes added within a group keep its prefix
+	for _, a := range attrs {
+		if h.group != "" && a.Key != "runner" && a.Key != "depth" {
+			a.Key = h.group + a.Key
+		}
+		h.attrs = append(append([]slog.Attr{}, h.attrs...), a)
+	}
+	return h
+}
+
+func (h textHandler) WithGroup(name string) slog.Handler {
+	h.group += name + "."
+	return h
+}
+
+// Protects the links set by linkLog: the same frame may be given by pointer
+// to Parallel substeps or monitors, which link it concurrently
+var logLinkLock sync.Mutex
+
+// Returns a TransformFunc (see Step.IterFrames) that links the Log embedded
+// on the frames to the frame's DefaultRunDealer, if any, and to the given
+// step runner otherwise, so that the leveled logs carry the runner ID.
+// Only frames given by pointer can be linked to their DefaultRunDealer, as
+// those given by value are copied
+func linkLog(runner *Run) TransformFunc {
+	return func(frame any) (any, error) {
+		v := reflect.ValueOf(frame)
+		if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return frame, nil
+		}
+		v = v.Elem()
+		f, ok := v.Type().FieldByName("Log")
+		if !ok || f.Type != logType || len(f.Index) != 1 {
+			return frame, nil
+		}
+		l := v.Field(f.Index[0]).Addr().Interface().(*Log)
+		var dealer *DefaultRunDealer
+		if f, ok := v.Type().FieldByName("DefaultRunDealer"); ok && f.Type == defaultRunDealerType && len(f.Index) == 1 {
+			dealer = v.Field(f.Index[0]).Addr().Interface().(*DefaultRunDealer)
+		}
+		logLinkLock.Lock()
+		defer logLinkLock.Unlock()
+		l.runner = runner
+		if dealer != nil {
+			l.dealer = dealer
+		}
+		return frame, nil
+	}
+}
+
+var logType = reflect.TypeOf(Log{})
@@ -0,0 +1,129 @@
+package frame2
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type loggingFrame struct {
+	Log
+	DefaultRunDealer
+}
+
+func (f *loggingFrame) Execute() error {
+	f.LogVerbosef("verbose")
+	f.Logf("from %v", "frame")
+	return nil
+}
+
+func TestLeveledLogs(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	assert.Assert(t, SetLogLevel("info"))
+	defer SetLogLevel("")
+
+	Logf("info %d", 1)
+	LogVerbosef("debug %d", 2)
+	LogLevelf(LevelWarn, "warn %d", 3)
+	assert.Assert(t, strings.Contains(buf.String(), "[INFO] info 1"), buf.String())
+	assert.Assert(t, !strings.Contains(buf.String(), "debug 2"), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), "[WARN] warn 3"), buf.String())
+
+	// This package is more verbose than the default
+	assert.Assert(t, SetLogLevel("error,github.com/hash-d/frame2/pkg=debug"))
+	buf.Reset()
+	LogVerbosef("debug %d", 4)
+	Step{}.Logf("step %d", 5)
+	assert.Assert(t, strings.Contains(buf.String(), "[DEBUG] debug 4"), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), "[DEBUG] step 5"), buf.String())
+
+	assert.Assert(t, SetLogLevel("warn,pkg=error"))
+	buf.Reset()
+	LogLevelf(LevelWarn, "warn %d", 6)
+	// Printf logs at LevelInfo
+	(&Log{}).Printf("printf %d", 7)
+	assert.Equal(t, buf.String(), "")
+
+	assert.ErrorContains(t, SetLogLevel("loud"), "invalid log level")
+}
+
+func TestFrameLogs(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	assert.Assert(t, SetLogLevel("info"))
+	defer SetLogLevel("")
+
+	frame := &loggingFrame{}
+	p := Phase{
+		Runner: &Run{T: t},
+		MainSteps: []Step{
+			{Modify: frame},
+		},
+	}
+	assert.Assert(t, p.Run())
+	// The frame's DefaultRunDealer got the modify runner
+	assert.Assert(t, strings.Contains(buf.String(), "[INFO]    R0.p0.s0.m0 from frame"), buf.String())
+	assert.Assert(t, !strings.Contains(buf.String(), "verbose"), buf.String())
+
+	// Other handlers get the runner and depth as attributes
+	var jsonBuf bytes.Buffer
+	SetLogHandler(slog.NewJSONHandler(&jsonBuf, nil))
+	defer SetLogHandler(nil)
+	frame.Logf("json")
+	assert.Assert(t, strings.Contains(jsonBuf.String(), `"msg":"json","runner":"R0.p0.s0.m0","depth":3`), jsonBuf.String())
+}
+
+func TestArtifactLogLevels(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	assert.Assert(t, SetLogLevel("debug,pkg=warn"))
+	defer SetLogLevel("")
+
+	path := filepath.Join(t.TempDir(), "output.log")
+	f, err := os.Create(path)
+	assert.Assert(t, err)
+	defer f.Close()
+	r := &Run{artifactLog: f}
+
+	// The lines dropped by the package's level do not reach the artifact
+	// log either
+	r.logf(LevelInfo, "runner %d", 1)
+	(&Log{runner: r}).Printf("frame %d", 2)
+	r.logf(LevelWarn, "runner %d", 3)
+	r.LogLevelf(LevelError, "frame %d", 4)
+	content, err := os.ReadFile(path)
+	assert.Assert(t, err)
+	assert.Assert(t, !strings.Contains(string(content), "runner 1"), string(content))
+	assert.Assert(t, !strings.Contains(string(content), "frame 2"), string(content))
+	assert.Assert(t, strings.Contains(string(content), "[WARN] runner 3"), string(content))
+	assert.Assert(t, strings.Contains(string(content), "[ERROR] R0 frame 4"), string(content))
+	assert.Equal(t, strings.Count(buf.String(), "\n"), 2, buf.String())
+}
+
+// The same frame may be linked by parallel substeps or monitors at once;
+// run with -race
+func TestLinkLogConcurrent(t *testing.T) {
+	frame := &loggingFrame{}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := linkLog(&Run{})(frame)
+			assert.Check(t, err)
+			assert.Check(t, frame.getRunner() != nil)
+		}()
+	}
+	wg.Wait()
+}
@@ -2,10 +2,12 @@ package frame2
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -22,15 +24,93 @@ type Monitor interface {
 type ValidatorConfig struct {
 	Name      string
 	Validator Validator
-	Interval  time.Duration
+	// If zero, DefaultMonitor.Interval is used
+	Interval time.Duration
+
+	// If set, each execution of the validator is given a context with
+	// this timeout (see injectContext).  Executions that take longer are
+	// recorded as failed when the timeout expires.  Validators that ignore
+	// the context are not waited for: they are left running on the
+	// background, while the monitor moves on to the next execution.
+	//
+	// As an abandoned execution may still be running, each execution works
+	// on its own copy of the validator (see snapshotValidators), which is
+	// the one that gets the context; the configured validator is not
+	// changed.  Validators with state on pointer, slice or map fields that
+	// they change should implement ValidatorCloner
+	Timeout time.Duration
+
+	// With Timeout, how long to wait for the validator to return after its
+	// context is done, before abandoning it; one second if zero
+	TimeoutGrace time.Duration
+
+	// If empty, DefaultMonitor.Thresholds is used
+	Thresholds MonitorThresholds
+}
+
+// Pass/fail criteria for the results of a monitored validator, checked on
+// DefaultMonitor.Report.  Zero values are not checked; to fail on any
+// error, set a MaxErrorPercent smaller than a single failure would cause,
+// such as 0.001
+type MonitorThresholds struct {
+	// The percentage (0-100) of failed executions above which the monitor
+	// fails
+	MaxErrorPercent float64
+
+	// The monitor fails if more than this number of executions fail in a
+	// row
+	MaxConsecutiveFailures int
+
+	// The monitor fails if any execution takes longer than this,
+	// whether it failed or not
+	MaxLatency time.Duration
+}
+
+func (t MonitorThresholds) isEmpty() bool {
+	return t == MonitorThresholds{}
+}
+
+// Checks the results of a validator against the thresholds, returning
+// an error that describes all breaches, if any
+func (t MonitorThresholds) check(name string, results []MonitorResult) error {
+	var count, failures, consecutive, maxConsecutive int
+	var maxLatency time.Duration
+	for _, r := range results {
+		count += 1
+		if r.Result != nil {
+			failures += 1
+			consecutive += 1
+			maxConsecutive = max(maxConsecutive, consecutive)
+		} else {
+			consecutive = 0
+		}
+		maxLatency = max(maxLatency, r.Duration)
+	}
+
+	var breaches []error
+	if t.MaxErrorPercent > 0 && count > 0 {
+		percent := float64(failures) / float64(count) * 100.0
+		if percent > t.MaxErrorPercent {
+			breaches = append(breaches, fmt.Errorf("monitor %q: %3.2f%% of executions failed (max %3.2f%%)", name, percent, t.MaxErrorPercent))
+		}
+	}
+	if t.MaxConsecutiveFailures > 0 && maxConsecutive > t.MaxConsecutiveFailures {
+		breaches = append(breaches, fmt.Errorf("monitor %q: %d consecutive failures (max %d)", name, maxConsecutive, t.MaxConsecutiveFailures))
+	}
+	if t.MaxLatency > 0 && maxLatency > t.MaxLatency {
+		breaches = append(breaches, fmt.Errorf("monitor %q: an execution took %v (max %v)", name, maxLatency, t.MaxLatency))
+	}
+	return errors.Join(breaches...)
 }
 
 type MonitorResult struct {
 	Timestamp time.Time
 	Duration  time.Duration
-	Step      *Step
-	Id        string
-	Result    error
+	// The step running on the test when the sample was taken, if any
+	Step *Step
+	// The runner ID of that step
+	Id     string
+	Result error
 }
 
 type DefaultMonitor struct {
@@ -45,14 +125,29 @@ type DefaultMonitor struct {
 	// Same as Validators, but allow for per-Validator configuration
 	ValidatorConfigs []ValidatorConfig
 
+	// If set, the output of the monitor and its validators is appended to
+	// this file.  Otherwise, it is discarded, so it does not mix with the
+	// test's output.  Output that the validators write directly to
+	// os.Stdout or os.Stderr is not affected
+	LogPath string
+
 	// This starts as a copy of ValidatorConfigs, to which the Validators
 	// are added, with the default configuration
 	validatorConfigs []ValidatorConfig
 
-	// TODO Path where the report should be put.  If not set, TODO
+	// The default pass/fail criteria for the validators; see
+	// MonitorThresholds
+	Thresholds MonitorThresholds
+
+	// If set, Report writes all samples to Path.csv and Path.json (see
+	// WriteCSV and WriteJSON)
 	Path string
 
+	// The results of each validator execution, by validator name.  They
+	// are written concurrently while the monitor runs; use GetResults
+	// to read them before the monitor's teardown
 	Results map[string][]MonitorResult
+	lock    sync.Mutex
 
 	Log
 
@@ -62,38 +157,91 @@ type DefaultMonitor struct {
 	// that will be called at TearDown
 	ctx    context.Context
 	finish context.CancelFunc
+
+	// The monitor goroutines, which need to finish before logFile is closed
+	running sync.WaitGroup
+	logFile *os.File
 }
 
-// Result() error
+// Logs a summary of the results of each validator, and checks them
+// against their thresholds.  If any were breached, an error listing all
+// breaches is returned, which fails the test on Run.Report
 func (d *DefaultMonitor) Report() error {
-	for k, validatorResult := range d.Results {
+	results := d.GetResults()
+
+	var breaches []error
+	for _, k := range sortedResultNames(results) {
+		validatorResult := results[k]
 		var count int
-		var errors int
+		var failures int
 		// Change this to a string or struct result, to be consumed and
 		// logged elsewhere?
 		log.Printf("Results for %v", k)
 		for _, r := range validatorResult {
 			count += 1
 			if r.Result != nil {
-				errors += 1
+				failures += 1
 			}
 		}
 		log.Printf(
 			"%d errors (%3.2f%%) errors in %d executions",
-			errors,
-			float32(errors)/float32(count)*100.0,
+			failures,
+			float32(failures)/float32(count)*100.0,
 			count,
 		)
+		if err := d.thresholds(k).check(k, validatorResult); err != nil {
+			log.Printf("Thresholds breached: %v", err)
+			breaches = append(breaches, err)
+		}
 	}
-	return nil
+	for _, outage := range d.Outages() {
+		log.Printf("Monitor %v", outage)
+	}
+	if d.Path != "" {
+		if err := d.writeSamples(); err != nil {
+			log.Printf("Failed to write the monitor samples: %v", err)
+		}
+	}
+	return errors.Join(breaches...)
+}
+
+// Returns a copy of the results collected so far; it is safe to call
+// while the monitor runs
+func (d *DefaultMonitor) GetResults() map[string][]MonitorResult {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ret := make(map[string][]MonitorResult, len(d.Results))
+	for k, v := range d.Results {
+		ret[k] = append([]MonitorResult{}, v...)
+	}
+	return ret
+}
+
+func (d *DefaultMonitor) addResult(name string, result MonitorResult) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.Results[name] = append(d.Results[name], result)
+}
+
+// The thresholds for the named validator: its own, if set on its
+// ValidatorConfig, or the monitor's
+func (d *DefaultMonitor) thresholds(name string) MonitorThresholds {
+	for _, configs := range [][]ValidatorConfig{d.validatorConfigs, d.ValidatorConfigs} {
+		for _, vc := range configs {
+			if vc.Name == name && !vc.Thresholds.isEmpty() {
+				return vc.Thresholds
+			}
+		}
+	}
+	return d.Thresholds
 }
 
 // This actually only sets up the monitor, internally.  The actual
 // execution of the monitor is on Monitor(r).
 //
 // For any validators configured on this Monitor with an empty
-// Logger configuration, the Monitor will replace it by a no-op
-// Monitor (log.New(io.Discard, "[M]", 0))
+// Logger configuration, the Monitor will replace it by its own
+// logger, which writes to LogPath or, if not set, discards the output
 func (m *DefaultMonitor) Execute() error {
 
 	var parentCtx context.Context
@@ -102,15 +250,25 @@ func (m *DefaultMonitor) Execute() error {
 	} else {
 		parentCtx = ContextOrDefault(m.runner.ctx)
 	}
+	// Created before anything can fail, as the Teardown is scheduled even
+	// if Execute returns an error
 	m.ctx, m.finish = context.WithCancel(parentCtx)
 
-	// TODO Change this by a file destination?
-	nlog := log.New(io.Discard, "[M]", 0)
-	//nlog = log.New(os.Stderr, "[M]", 0)
-	//m.OrSetLogger(nlog)
+	var out io.Writer = io.Discard
+	if m.LogPath != "" {
+		f, err := os.OpenFile(m.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open the monitor log: %w", err)
+		}
+		m.logFile = f
+		out = f
+	}
+	nlog := log.New(out, "[M] ", log.LstdFlags)
 	m.SetLogger(nlog)
 
+	m.lock.Lock()
 	m.Results = map[string][]MonitorResult{}
+	m.lock.Unlock()
 
 	interval := m.Interval
 	if interval == 0 {
@@ -121,28 +279,50 @@ func (m *DefaultMonitor) Execute() error {
 	// runs do not break the test
 	monitorRunner := &Run{}
 
+	m.validatorConfigs = nil
+	for _, vc := range m.ValidatorConfigs {
+		if vc.Interval == 0 {
+			vc.Interval = interval
+		}
+		m.validatorConfigs = append(m.validatorConfigs, vc)
+	}
 	for k, v := range m.Validators {
-		if val, ok := v.(RunDealer); ok {
+		m.validatorConfigs = append(m.validatorConfigs, ValidatorConfig{
+			Name:      k,
+			Validator: v,
+			Interval:  interval,
+		})
+	}
+
+	names := map[string]bool{}
+	for _, vc := range m.validatorConfigs {
+		if names[vc.Name] {
+			m.closeLog()
+			return fmt.Errorf("monitor validator name %q is used more than once", vc.Name)
+		}
+		names[vc.Name] = true
+		if val, ok := vc.Validator.(RunDealer); ok {
 			val.SetRunner(monitorRunner, MonitorRunner)
 		} else {
 			panic(fmt.Sprintf(
 				"Validator %T on %s is not a RunDealer, and cannot be used as a monitor",
-				v,
+				vc.Validator,
 				m.runner.GetId(),
 			))
 		}
-		OrSetLogger(v, nlog)
-		vc := ValidatorConfig{
-			Name:      k,
-			Validator: v,
-			Interval:  interval,
-		}
-		m.validatorConfigs = append(m.validatorConfigs, vc)
+		OrSetLogger(vc.Validator, nlog)
 	}
 
 	return nil
 }
 
+func (m *DefaultMonitor) closeLog() {
+	if m.logFile != nil {
+		m.logFile.Close()
+		m.logFile = nil
+	}
+}
+
 func (m *DefaultMonitor) Monitor(runner *Run) error {
 
 	m.runner = runner
@@ -156,46 +336,88 @@ func (m *DefaultMonitor) Monitor(runner *Run) error {
 func (m *DefaultMonitor) goMonitor(vc ValidatorConfig) {
 	ctx := m.ctx
 
-	log.Printf("Starting Monitor %+v", vc)
+	log.Printf("Starting Monitor %q (%T) with interval %v", vc.Name, vc.Validator, vc.Interval)
 
+	m.running.Add(1)
 	go func() {
-		os.Stdout = nil
-		os.Stderr = nil
-		// log.SetOutput(io.Discard)
+		defer m.running.Done()
 		done := ctx.Done()
 
 		for ctx.Err() == nil {
 			start := time.Now()
-			// These two lines should not appear on the logs, as m.Log should be
-			// discarding its contents.  If it shows up, it's a problem
-			m.Log.Printf("v========================== executing ===========v")
-			err := vc.Validator.Validate()
+			m.Log.Printf("%v: executing", vc.Name)
+			step, stepId := m.runner.getCurrentStep()
+			err := m.validate(ctx, vc)
 			end := time.Now()
 			elapsed := end.Sub(start)
-			// TODO: this needs sync.Lock, to avoid "fatal error: concurrent map writes"
-			m.Results[vc.Name] = append(m.Results[vc.Name], MonitorResult{
+			m.addResult(vc.Name, MonitorResult{
 				Timestamp: start,
 				Duration:  elapsed,
+				Step:      step,
+				Id:        stepId,
 				Result:    err,
 			})
 
-			m.Log.Printf("%v", elapsed)
+			m.Log.Printf("%v: executed in %v: %v", vc.Name, elapsed, err)
 			timeout := time.After(vc.Interval)
-			m.Log.Printf("^========================== executed ===========^")
 			select {
 			case <-done:
 			case <-timeout:
 			}
 		}
-		log.Printf("Monitor finished: %#v", vc)
+		m.Log.Printf("Monitor %q finished", vc.Name)
 	}()
 
 }
 
+// Runs the validator once, within its timeout, if any
+func (m *DefaultMonitor) validate(ctx context.Context, vc ValidatorConfig) error {
+	if vc.Timeout == 0 {
+		return vc.Validator.Validate()
+	}
+	ctx, cancel := context.WithTimeout(ctx, vc.Timeout)
+	defer cancel()
+	grace := vc.TimeoutGrace
+	if grace == 0 {
+		grace = defaultTimeoutGrace
+	}
+	// Copies given by pointer get the context in place; those given by
+	// value, on the returned copy
+	validator := snapshotValidators([]Validator{vc.Validator})[0]
+	if injected, err := injectContext(frameworkContext{ctx})(validator); err == nil {
+		if v, ok := injected.(Validator); ok {
+			validator = v
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- validator.Validate()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// As on Run.runWithTimeout, validators that honor the context get
+		// some time to return
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(grace):
+			return fmt.Errorf("validator did not return within its timeout of %v: %w", vc.Timeout, ctx.Err())
+		}
+	}
+}
+
+// Stops the monitor goroutines.  The monitor log is closed once all of
+// them finish their current execution
 func (m *DefaultMonitor) Teardown() Executor {
 	return &Procedure{
 		Fn: func() {
 			m.finish()
+			go func() {
+				m.running.Wait()
+				m.closeLog()
+			}()
 		},
 	}
 }
@@ -0,0 +1,288 @@
+package frame2
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func monitorResults(latency time.Duration, failed ...bool) []MonitorResult {
+	var ret []MonitorResult
+	for _, f := range failed {
+		var err error
+		if f {
+			err = errors.New("failed")
+		}
+		ret = append(ret, MonitorResult{Duration: latency, Result: err})
+	}
+	return ret
+}
+
+func TestMonitorThresholds(t *testing.T) {
+	m := &DefaultMonitor{
+		Thresholds: MonitorThresholds{
+			MaxErrorPercent: 30,
+		},
+		ValidatorConfigs: []ValidatorConfig{
+			{
+				Name: "strict",
+				Thresholds: MonitorThresholds{
+					MaxConsecutiveFailures: 1,
+					MaxLatency:             time.Second,
+				},
+			},
+		},
+		Results: map[string][]MonitorResult{
+			// 25% failed: within the default threshold
+			"default": monitorResults(time.Millisecond, true, false, false, false),
+		},
+	}
+	assert.Assert(t, m.Report())
+
+	// 50% failed
+	m.Results["default"] = monitorResults(time.Millisecond, true, false, true, false)
+	err := m.Report()
+	assert.ErrorContains(t, err, `monitor "default": 50.00% of executions failed`)
+
+	m.Results = map[string][]MonitorResult{
+		// The strict config does not check the error percentage, but
+		// the failures in a row and the latency
+		"strict": monitorResults(2*time.Second, true, true, false, false, false, false),
+	}
+	err = m.Report()
+	assert.ErrorContains(t, err, "2 consecutive failures (max 1)")
+	assert.ErrorContains(t, err, "an execution took 2s (max 1s)")
+	assert.Assert(t, !strings.Contains(err.Error(), "% of executions failed"))
+}
+
+type countingValidator struct {
+	count *atomic.Int32
+	Log
+	DefaultRunDealer
+}
+
+func (v *countingValidator) Validate() error {
+	v.count.Add(1)
+	return nil
+}
+
+// Several validators write their results concurrently, while they are
+// read; this is meant to be run with -race
+func TestMonitorConcurrentResults(t *testing.T) {
+	var count atomic.Int32
+	validators := map[string]Validator{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		validators[name] = &countingValidator{count: &count}
+	}
+	m := &DefaultMonitor{
+		Validators: validators,
+		Interval:   time.Millisecond,
+	}
+	assert.Assert(t, m.Execute())
+	assert.Assert(t, m.Monitor(&Run{T: t}))
+	for count.Load() < 40 {
+		_ = m.GetResults()
+		time.Sleep(time.Millisecond)
+	}
+	assert.Assert(t, m.Teardown().Execute())
+	assert.Assert(t, m.Report())
+	assert.Equal(t, len(m.GetResults()), 4)
+}
+
+func TestMonitorOutages(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	upgrade := &Step{Doc: "upgrade"}
+	sample := func(second int, id string, step *Step, failed bool) MonitorResult {
+		var err error
+		if failed {
+			err = errors.New("connection refused")
+		}
+		return MonitorResult{
+			Timestamp: start.Add(time.Duration(second) * time.Second),
+			Duration:  100 * time.Millisecond,
+			Step:      step,
+			Id:        id,
+			Result:    err,
+		}
+	}
+	m := &DefaultMonitor{
+		Results: map[string][]MonitorResult{
+			"curl": {
+				sample(0, "p0.s0", nil, false),
+				sample(1, "p0.s1", upgrade, true),
+				sample(2, "p0.s1", upgrade, true),
+				sample(3, "p0.s2", nil, true),
+				sample(4, "p0.s2", nil, false),
+				sample(5, "p0.s3", nil, true),
+			},
+		},
+	}
+
+	outages := m.Outages()
+	assert.Equal(t, len(outages), 2)
+	assert.Equal(t, outages[0].Duration(), 3*time.Second)
+	assert.Equal(t, outages[0].Failures, 3)
+	assert.DeepEqual(t, outages[0].StepIds, []string{"p0.s1", "p0.s2"})
+	assert.Assert(t, strings.Contains(outages[0].String(), "during p0.s1 (upgrade), p0.s2"), outages[0].String())
+	// The last one has no successful sample after it
+	assert.Equal(t, outages[1].Duration(), 100*time.Millisecond)
+
+	var buf strings.Builder
+	assert.Assert(t, m.WriteCSV(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, len(lines), 7)
+	assert.Equal(t, lines[0], "validator,timestamp,duration,stepId,stepDoc,error")
+	assert.Equal(t, lines[2], "curl,2024-01-01T10:00:01Z,0.100000,p0.s1,upgrade,connection refused")
+
+	buf.Reset()
+	assert.Assert(t, m.WriteJSON(&buf))
+	assert.Assert(t, strings.Contains(buf.String(), `"stepDoc": "upgrade"`), buf.String())
+}
+
+func TestCurrentStep(t *testing.T) {
+	root := &Run{}
+	runner := root.ChildWithT(nil, StepRunner)
+	step, id := root.getCurrentStep()
+	assert.Assert(t, step == nil)
+
+	restore := runner.setCurrentStep(Step{Doc: "outer"})
+	inner := runner.ChildWithT(nil, StepRunner)
+	restoreInner := inner.setCurrentStep(Step{Doc: "inner"})
+	step, id = root.getCurrentStep()
+	assert.Equal(t, step.Doc, "inner")
+	assert.Equal(t, id, "R0.s0.s0")
+
+	restoreInner()
+	step, id = inner.getCurrentStep()
+	assert.Equal(t, step.Doc, "outer")
+	assert.Equal(t, id, "R0.s0")
+	restore()
+	step, _ = root.getCurrentStep()
+	assert.Assert(t, step == nil)
+}
+
+type slowValidator struct {
+	Ctx   context.Context
+	delay time.Duration
+	Log
+	DefaultRunDealer
+}
+
+func (v *slowValidator) Validate() error {
+	v.Log.Printf("validating")
+	select {
+	case <-time.After(v.delay):
+		return nil
+	case <-v.Ctx.Done():
+		return v.Ctx.Err()
+	}
+}
+
+// Ignores its context, and only returns when released
+type blockingValidator struct {
+	release chan struct{}
+	Log
+	DefaultRunDealer
+}
+
+func (v *blockingValidator) Validate() error {
+	<-v.release
+	return nil
+}
+
+func TestMonitorValidatorConfigs(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "monitor.log")
+	m := &DefaultMonitor{
+		Validators: map[string]Validator{
+			"default": &countingValidator{count: &atomic.Int32{}},
+		},
+		ValidatorConfigs: []ValidatorConfig{
+			{
+				Name:      "slow",
+				Validator: &slowValidator{delay: time.Hour},
+				Interval:  time.Millisecond,
+				Timeout:   10 * time.Millisecond,
+			},
+		},
+		Interval: time.Hour,
+		LogPath:  logPath,
+	}
+	assert.Assert(t, m.Execute())
+	assert.Assert(t, m.Monitor(&Run{T: t}))
+	for len(m.GetResults()["slow"]) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Assert(t, m.Teardown().Execute())
+
+	results := m.GetResults()
+	// The default interval is an hour, so the other validator ran once
+	assert.Equal(t, len(results["default"]), 1)
+	for _, r := range results["slow"] {
+		assert.Assert(t, errors.Is(r.Result, context.DeadlineExceeded), r.Result)
+	}
+
+	m.running.Wait()
+	content, err := os.ReadFile(logPath)
+	assert.Assert(t, err)
+	assert.Assert(t, strings.Contains(string(content), "[M] "), string(content))
+	assert.Assert(t, strings.Contains(string(content), "validating"), string(content))
+
+	duplicate := &DefaultMonitor{
+		Validators: map[string]Validator{
+			"a": &countingValidator{count: &atomic.Int32{}},
+		},
+		ValidatorConfigs: []ValidatorConfig{
+			{Name: "a", Validator: &countingValidator{count: &atomic.Int32{}}},
+		},
+	}
+	assert.ErrorContains(t, duplicate.Execute(), `"a" is used more than once`)
+}
+
+// Validators that ignore the context are abandoned on the timeout, and
+// each execution gets its own copy, so the abandoned ones can keep running
+func TestMonitorValidatorAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	validator := &blockingValidator{release: release}
+	vc := ValidatorConfig{
+		Name:         "blocking",
+		Validator:    validator,
+		Timeout:      10 * time.Millisecond,
+		TimeoutGrace: time.Millisecond,
+	}
+	m := &DefaultMonitor{}
+	for i := 0; i < 2; i++ {
+		err := m.validate(context.Background(), vc)
+		assert.Assert(t, errors.Is(err, context.DeadlineExceeded), err)
+		assert.ErrorContains(t, err, "did not return within its timeout of 10ms")
+	}
+	assert.Assert(t, validator.Ctx == nil, "the configured validator should not be changed")
+}
+
+// The teardown of a monitor is scheduled even if its Execute fails, so it
+// must work on that case as well
+func TestMonitorFailedExecute(t *testing.T) {
+	m := &DefaultMonitor{
+		LogPath: filepath.Join(t.TempDir(), "missing", "monitor.log"),
+	}
+	assert.ErrorContains(t, m.Execute(), "failed to open the monitor log")
+	assert.Assert(t, m.Teardown().Execute())
+
+	duplicate := &DefaultMonitor{
+		Validators: map[string]Validator{
+			"a": &countingValidator{count: &atomic.Int32{}},
+		},
+		ValidatorConfigs: []ValidatorConfig{
+			{Name: "a", Validator: &countingValidator{count: &atomic.Int32{}}},
+		},
+	}
+	assert.Assert(t, duplicate.Execute() != nil)
+	assert.Assert(t, duplicate.Teardown().Execute())
+}
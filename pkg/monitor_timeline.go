@@ -0,0 +1,228 @@
+package frame2
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Records the step as the one currently running, for the monitor samples
+// taken from now on, and returns a function that restores the previous
+// one.  It is meant to be used as defer r.setCurrentStep(step)()
+//
+// The step is copied, so later changes to it (such as those from
+// disruptors) do not race with the monitors reading it.  With parallel
+// steps, the one that started last is used
+func (r *Run) setCurrentStep(step Step) func() {
+	root := r.getRoot()
+	id := r.GetId()
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	previous, previousId := root.currentStep, root.currentStepId
+	root.currentStep, root.currentStepId = &step, id
+	return func() {
+		runnerLock.Lock()
+		defer runnerLock.Unlock()
+		// A parallel step may have replaced it in the meantime; that one
+		// will restore its own previous step
+		if root.currentStepId == id {
+			root.currentStep, root.currentStepId = previous, previousId
+		}
+	}
+}
+
+// Returns the step currently running on the test, and the ID of its
+// runner
+func (r *Run) getCurrentStep() (*Step, string) {
+	if r == nil {
+		return nil, ""
+	}
+	root := r.getRoot()
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	return root.currentStep, root.currentStepId
+}
+
+// A sequence of failed samples from a monitored validator, and the steps
+// that were running during it
+type MonitorOutage struct {
+	Validator string
+	Start     time.Time
+	// The start of the first successful sample after the outage or, if
+	// none, the end of its last failed sample
+	End      time.Time
+	Failures int
+	// The runner IDs of the steps running during the outage, in order
+	StepIds []string
+	// The Docs of those steps, on the same order
+	StepDocs []string
+}
+
+func (o MonitorOutage) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+func (o MonitorOutage) String() string {
+	var steps []string
+	for i, id := range o.StepIds {
+		if o.StepDocs[i] != "" {
+			steps = append(steps, fmt.Sprintf("%v (%s)", id, o.StepDocs[i]))
+		} else {
+			steps = append(steps, id)
+		}
+	}
+	during := "no steps"
+	if len(steps) > 0 {
+		during = strings.Join(steps, ", ")
+	}
+	return fmt.Sprintf(
+		"outage of %v on %q from %v to %v (%d failures), during %s",
+		o.Duration().Round(time.Millisecond),
+		o.Validator,
+		o.Start.Format(time.TimeOnly),
+		o.End.Format(time.TimeOnly),
+		o.Failures,
+		during,
+	)
+}
+
+// Returns the outage windows on the results, by validator name and then
+// by time
+func (d *DefaultMonitor) Outages() []MonitorOutage {
+	results := d.GetResults()
+	var ret []MonitorOutage
+	for _, name := range sortedResultNames(results) {
+		var current *MonitorOutage
+		for _, r := range results[name] {
+			if r.Result == nil {
+				if current != nil {
+					current.End = r.Timestamp
+					ret = append(ret, *current)
+					current = nil
+				}
+				continue
+			}
+			if current == nil {
+				current = &MonitorOutage{Validator: name, Start: r.Timestamp}
+			}
+			current.Failures++
+			current.End = r.Timestamp.Add(r.Duration)
+			if r.Id != "" && (len(current.StepIds) == 0 || current.StepIds[len(current.StepIds)-1] != r.Id) {
+				current.StepIds = append(current.StepIds, r.Id)
+				var doc string
+				if r.Step != nil {
+					doc = r.Step.Doc
+				}
+				current.StepDocs = append(current.StepDocs, doc)
+			}
+		}
+		if current != nil {
+			ret = append(ret, *current)
+		}
+	}
+	return ret
+}
+
+func sortedResultNames(results map[string][]MonitorResult) []string {
+	names := make([]string, 0, len(results))
+	for k := range results {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A sample as exported by WriteCSV and WriteJSON
+type monitorSample struct {
+	Validator string    `json:"validator"`
+	Timestamp time.Time `json:"timestamp"`
+	Duration  float64   `json:"duration"` // in seconds
+	StepId    string    `json:"stepId,omitempty"`
+	StepDoc   string    `json:"stepDoc,omitempty"`
+	Err       string    `json:"error,omitempty"`
+}
+
+// The samples of all validators, by validator name and then by time
+func (d *DefaultMonitor) samples() []monitorSample {
+	results := d.GetResults()
+	var ret []monitorSample
+	for _, name := range sortedResultNames(results) {
+		for _, r := range results[name] {
+			s := monitorSample{
+				Validator: name,
+				Timestamp: r.Timestamp,
+				Duration:  r.Duration.Seconds(),
+				StepId:    r.Id,
+			}
+			if r.Step != nil {
+				s.StepDoc = r.Step.Doc
+			}
+			if r.Result != nil {
+				s.Err = r.Result.Error()
+			}
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// Writes all samples as CSV, with a header line.  The duration is in
+// seconds, and the error is empty for successful samples
+func (d *DefaultMonitor) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"validator", "timestamp", "duration", "stepId", "stepDoc", "error"})
+	for _, s := range d.samples() {
+		_ = cw.Write([]string{
+			s.Validator,
+			s.Timestamp.Format(time.RFC3339Nano),
+			fmt.Sprintf("%f", s.Duration),
+			s.StepId,
+			s.StepDoc,
+			s.Err,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Writes all samples as a JSON array
+func (d *DefaultMonitor) WriteJSON(w io.Writer) error {
+	samples := d.samples()
+	if samples == nil {
+		samples = []monitorSample{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(samples)
+}
+
+// Writes the samples to Path.csv and Path.json
+func (d *DefaultMonitor) writeSamples() error {
+	for _, f := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{
+		{".csv", d.WriteCSV},
+		{".json", d.WriteJSON},
+	} {
+		file, err := os.Create(d.Path + f.ext)
+		if err != nil {
+			return fmt.Errorf("failed to create the monitor samples file: %w", err)
+		}
+		err = f.write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %q: %w", d.Path+f.ext, err)
+		}
+		log.Printf("Monitor samples written to %q", d.Path+f.ext)
+	}
+	return nil
+}
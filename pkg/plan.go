@@ -0,0 +1,155 @@
+package frame2
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Used to compare steps before and after a disruptor's Inspect.  Keys are
+// sorted and addresses left out, so that equal steps give equal dumps
+var planSpewer = spew.ConfigState{
+	Indent:                  " ",
+	MaxDepth:                6,
+	DisablePointerAddresses: true,
+	DisableCapacities:       true,
+	SortKeys:                true,
+}
+
+// Whether the plan mode was requested, on ENV_PLAN or with the -frame2-plan
+// flag (see Flag)
+func planMode() bool {
+	return settingPlan.Enabled()
+}
+
+// Walks the phase without executing it, logging each step's runner ID, Doc
+// and frames, and which disruptors would change the step on Inspect.  No
+// Execute, Validate or hooks are called.
+//
+// Steps are walked with the same runner kinds as on a real run, so the IDs
+// are the same, as long as the structure of the test does not depend on
+// the results of its steps.  Frames that build their own phases on
+// Execute cannot be walked; only Modify steps that are a Phase are
+// entered
+func (p *Phase) plan(runner *Run) error {
+	if runner == nil {
+		runner = &Run{}
+	}
+	p.DefaultRunDealer.Runner = runner.ChildWithT(runner.T, PhaseRunner)
+	depth := p.GetRunner().depth()
+	indent := strings.Repeat("  ", depth)
+	log.Printf("[PLAN] %s%v Phase %q: %s", indent, p.GetRunner().GetId(), p.Name, p.Doc)
+	if p.Timeout > 0 {
+		log.Printf("[PLAN] %s (times out after %v)", indent, p.Timeout)
+	}
+
+	for _, list := range []struct {
+		name  string
+		steps []Step
+		kind  RunnerType
+	}{
+		{"Setup", p.Setup, SetupRunner},
+		{"MainSteps", p.MainSteps, StepRunner},
+		{"Teardown", p.Teardown, TearDownRunner},
+	} {
+		if len(list.steps) == 0 {
+			continue
+		}
+		log.Printf("[PLAN] %s %s:", indent, list.name)
+		for _, step := range list.steps {
+			p.planStep(step, p.GetRunner(), list.kind)
+		}
+	}
+	return nil
+}
+
+func (p *Phase) planStep(step Step, parent *Run, kind RunnerType) {
+	name := ""
+	if step.Name != "" {
+		name = fmt.Sprintf(" %q", step.Name)
+		kind = SubTestRunner
+	}
+	// As on processStep, skipped steps get no runner
+	if step.SkipWhen {
+		indent := strings.Repeat("  ", parent.depth()+1)
+		log.Printf("[PLAN] %s- Step%s (skipped): %s", indent, name, step.Doc)
+		return
+	}
+	stepRunner := parent.ChildWithT(parent.T, kind)
+	id := stepRunner.GetId()
+	indent := strings.Repeat("  ", stepRunner.depth())
+
+	log.Printf("[PLAN] %s%v Step%s: %s", indent, id, name, step.Doc)
+
+	// The disruptors are applied in order, as on a real run; each is
+	// checked for the changes it does on top of the previous ones
+	for _, disruptor := range p.GetRunner().getScopedDisruptors() {
+		if _, ok := disruptor.Disruptor.(Inspector); !ok {
+			continue
+		}
+		before := planSpewer.Sdump(step)
+		if err := planInspect(disruptor, &step, p, id); err != nil {
+			log.Printf("[PLAN] %s  disruptor %v: %v", indent, disruptor.DisruptorEnvValue(), err)
+			continue
+		}
+		if planSpewer.Sdump(step) != before {
+			log.Printf("[PLAN] %s  disruptor %v changes this step", indent, disruptor.DisruptorEnvValue())
+		}
+	}
+
+	_ = step.IterFrames(func(frame any) (any, error) {
+		role := "Validator"
+		if step.Modify != nil && frame == any(step.Modify) {
+			role = "Modify"
+		}
+		log.Printf("[PLAN] %s  %s %T", indent, role, frame)
+		return frame, nil
+	})
+	if step.Timeout > 0 {
+		log.Printf("[PLAN] %s  (times out after %v)", indent, step.Timeout)
+	}
+	if step.ValidatorFinal {
+		log.Printf("[PLAN] %s  (validators re-run as final)", indent)
+	}
+	if step.ValidatorSubFinal {
+		log.Printf("[PLAN] %s  (validators re-run as sub-final)", indent)
+	}
+
+	if _, ok := step.Modify.(TearDowner); ok {
+		log.Printf("[PLAN] %s  (registers an automatic teardown)", indent)
+	}
+
+	if step.Modify != nil {
+		modifyRunner := stepRunner.ChildWithT(stepRunner.T, ModifyRunner)
+		if phase, ok := step.Modify.(Phase); ok {
+			_ = phase.plan(modifyRunner)
+		}
+	}
+
+	substeps := step.GetSubsteps()
+	if len(substeps) > 0 && step.Parallel {
+		log.Printf("[PLAN] %s  (substeps run in parallel)", indent)
+	}
+	for _, substep := range substeps {
+		// As on processStep_ and runParallel: sequential substeps hang
+		// from the phase runner, and parallel ones from their branches
+		if step.Parallel {
+			p.planStep(*substep, stepRunner.ChildWithT(stepRunner.T, ParallelRunner), StepRunner)
+		} else {
+			p.planStep(*substep, p.GetRunner(), SubTestRunner)
+		}
+	}
+}
+
+// Calls the disruptor's Inspect on the step, turning any panics into errors
+func planInspect(disruptor scopedDisruptor, step *Step, p *Phase, id string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic on Inspect: %v", r)
+		}
+	}()
+	disruptor.inspect(step, p, id)
+	return nil
+}
@@ -0,0 +1,122 @@
+package frame2
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// Fails the test if executed or validated
+type planFrame struct {
+	t *testing.T
+	Log
+}
+
+func (f *planFrame) Execute() error {
+	f.t.Errorf("frame executed on plan mode")
+	return nil
+}
+
+func (f *planFrame) Validate() error {
+	f.t.Errorf("frame validated on plan mode")
+	return nil
+}
+
+// Changes the steps whose Doc is "target"
+type planChanger struct{}
+
+func (planChanger) DisruptorEnvValue() string { return "PLAN_CHANGER" }
+
+func (planChanger) Inspect(step *Step, phase *Phase) {
+	if step.Doc == "target" {
+		step.Doc = "changed"
+	}
+}
+
+type planPanicker struct{}
+
+func (planPanicker) DisruptorEnvValue() string { return "PLAN_PANICKER" }
+
+func (planPanicker) Inspect(step *Step, phase *Phase) {
+	panic("boom")
+}
+
+func TestPlan(t *testing.T) {
+	t.Setenv(ENV_PLAN, "1")
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	frame := &planFrame{t: t}
+	p := Phase{
+		Runner: &Run{
+			T:                  t,
+			RequiredDisruptors: []Disruptor{planChanger{}, planPanicker{}},
+		},
+		Doc: "plan test",
+		Setup: []Step{
+			{
+				Doc:    "setup",
+				Modify: frame,
+			},
+		},
+		MainSteps: []Step{
+			{
+				Doc:        "target",
+				Name:       "named",
+				Validators: []Validator{frame},
+			}, {
+				Doc: "parent",
+				Substeps: []*Step{
+					{Doc: "sub", Modify: frame},
+				},
+			}, {
+				Doc:      "skipped",
+				SkipWhen: true,
+			},
+		},
+		Teardown: []Step{
+			{
+				Doc:    "teardown",
+				Modify: frame,
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+
+	out := buf.String()
+	for _, expected := range []string{
+		`p0 Phase "": plan test`,
+		"p0.set0 Step: setup",
+		"Modify *frame2.planFrame",
+		`SubT1 Step "named": target`,
+		"disruptor PLAN_CHANGER changes this step",
+		"disruptor PLAN_PANICKER: panic on Inspect: boom",
+		"Validator *frame2.planFrame",
+		"p0.s2 Step: parent",
+		"SubT3 Step: sub",
+		"- Step (skipped): skipped",
+		"p0.TD4 Step: teardown",
+	} {
+		assert.Assert(t, bytes.Contains(buf.Bytes(), []byte(expected)), fmt.Sprintf("%q not found on:\n%s", expected, out))
+	}
+	// Only the step whose Doc was "target" is changed
+	assert.Equal(t, bytes.Count(buf.Bytes(), []byte("changes this step")), 1)
+}
+
+func TestPlanFlag(t *testing.T) {
+	t.Setenv(ENV_PLAN, "")
+	assert.Assert(t, setPlanFlag("false"))
+	assert.Assert(t, !planMode())
+	assert.Assert(t, setPlanFlag("x") != nil)
+	assert.Assert(t, !planMode())
+	assert.Assert(t, setPlanFlag("true"))
+	assert.Assert(t, planMode())
+	// false turns off a plan mode given on the environment
+	assert.Assert(t, setPlanFlag("false"))
+	assert.Assert(t, !planMode())
+}
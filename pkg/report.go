@@ -0,0 +1,498 @@
+package frame2
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How many log lines are kept on each Runner, for the report excerpts.
+// Only the last lines are kept
+const reportLogLines = 200
+
+// Protects the report information on the Runners, as events may be
+// generated concurrently
+var recordLock sync.Mutex
+
+// What a Runner knows about its own execution, for reporting
+type runRecord struct {
+	name     string
+	doc      string
+	frame    string
+	start    time.Time
+	duration time.Duration
+	err      error
+	attempts int
+	started  bool
+	logLines []string
+}
+
+// Updates the runner's record with the information from an event it
+// generated
+func (r *Run) record(e Event) {
+	recordLock.Lock()
+	defer recordLock.Unlock()
+	rec := &r.result
+	if e.Name != "" {
+		rec.name = e.Name
+	}
+	if e.Doc != "" && rec.doc == "" {
+		rec.doc = e.Doc
+	}
+	if e.Frame != "" && rec.frame == "" {
+		rec.frame = e.Frame
+	}
+	switch e.Kind {
+	case EventPhaseStart, EventStepStart, EventModifyStart, EventValidatorStart, EventHookStart:
+		if !rec.started {
+			rec.start = e.Time
+			rec.started = true
+		}
+	case EventPhaseEnd, EventStepEnd, EventModifyEnd, EventValidatorEnd, EventHookEnd:
+		if !rec.started {
+			rec.start = e.Time.Add(-e.Duration)
+			rec.started = true
+		}
+		rec.duration = e.Time.Sub(rec.start)
+		rec.err = e.Err
+	case EventRetryAttempt:
+		if e.Attempt > rec.attempts {
+			rec.attempts = e.Attempt
+		}
+	}
+}
+
+func (r *Run) recordLogLine(line string) {
+	recordLock.Lock()
+	defer recordLock.Unlock()
+	r.result.logLines = append(r.result.logLines, line)
+	if len(r.result.logLines) > reportLogLines {
+		r.result.logLines = r.result.logLines[len(r.result.logLines)-reportLogLines:]
+	}
+}
+
+// A FrameLogger that keeps a copy of the lines it prints on a Runner,
+// to be used as log excerpts on the reports
+type recordingLogger struct {
+	FrameLogger
+	runner *Run
+}
+
+// The lines also go to the runner's artifact logs; see openArtifacts
+func (l recordingLogger) Printf(format string, v ...any) {
+	line := fmt.Sprintf(format, v...)
+	l.runner.recordLogLine(line)
+	l.runner.writeArtifactLog(line)
+	l.FrameLogger.Printf(format, v...)
+}
+
+// A node on the report tree; each node corresponds to a Runner
+type ReportNode struct {
+	Id       string
+	Type     RunnerType
+	Test     string
+	Name     string
+	Doc      string
+	Frame    string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+	Attempts int
+	Log      []string
+	Children []*ReportNode
+}
+
+// Whether the runner of this node returned an error other than an
+// ErrSkip
+func (n *ReportNode) Failed() bool {
+	return n.Err != nil && !n.Skipped()
+}
+
+// Whether the runner of this node returned an ErrSkip
+func (n *ReportNode) Skipped() bool {
+	_, ok := AsSkip(n.Err)
+	return ok
+}
+
+// The number of steps on a report tree, by their result
+type StepCounts struct {
+	Passed  int
+	Failed  int
+	Skipped int
+}
+
+func (c StepCounts) String() string {
+	return fmt.Sprintf("%d passed, %d failed, %d skipped", c.Passed, c.Failed, c.Skipped)
+}
+
+// Counts the steps on the node and its descendants; steps within failed
+// steps are counted on their own
+func (n *ReportNode) StepCounts() StepCounts {
+	var c StepCounts
+	n.walk(func(node *ReportNode) {
+		switch node.Type {
+		case SetupRunner, StepRunner, SubTestRunner, TearDownRunner:
+		default:
+			return
+		}
+		switch {
+		case node.Skipped():
+			c.Skipped++
+		case node.Failed():
+			c.Failed++
+		default:
+			c.Passed++
+		}
+	})
+	return c
+}
+
+// A title for the node, from its name, doc or frame
+func (n *ReportNode) Title() string {
+	var parts []string
+	if n.Name != "" {
+		parts = append(parts, n.Name)
+	}
+	if n.Doc != "" {
+		parts = append(parts, n.Doc)
+	}
+	if len(parts) == 0 && n.Frame != "" {
+		parts = append(parts, n.Frame)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, n.Type.String())
+	}
+	return strings.Join(parts, ": ")
+}
+
+// Returns the tree of Runners under the root of r, with the information
+// recorded during their execution.  Runners that did not execute anything
+// are pruned from the tree.
+func (r *Run) ReportTree() *ReportNode {
+	return r.getRoot().reportNode()
+}
+
+func (r *Run) reportNode() *ReportNode {
+	recordLock.Lock()
+	rec := r.result
+	rec.logLines = append([]string{}, r.result.logLines...)
+	recordLock.Unlock()
+
+	node := &ReportNode{
+		Id:       r.GetId(),
+		Type:     r.kind,
+		Name:     rec.name,
+		Doc:      rec.doc,
+		Frame:    rec.frame,
+		Start:    rec.start,
+		Duration: rec.duration,
+		Err:      rec.err,
+		Attempts: rec.attempts,
+		Log:      rec.logLines,
+	}
+	if r.T != nil {
+		node.Test = r.T.Name()
+	}
+	if r.parent == nil && r.Doc != "" {
+		node.Doc = r.Doc
+	}
+	for _, c := range r.children {
+		if cn := c.reportNode(); cn != nil {
+			node.Children = append(node.Children, cn)
+		}
+	}
+	if !rec.started && len(node.Children) == 0 && r.parent != nil {
+		return nil
+	}
+	return node
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// Writes the report tree of the test as JUnit XML.  Each Runner that
+// executed something becomes a testcase, named after its ID and title,
+// and classed by its test name.  Monitor results are reported on their
+// own testsuite
+//
+// Runners that returned an ErrSkip are reported as skipped.  Any other
+// error is only reported as a failure if the parent node also failed.
+// Otherwise, it was handled by the framework (an expected error, a
+// validator that succeeded on a later attempt, etc), and it is listed
+// only on the testcase output
+func (r *Run) WriteJUnit(w io.Writer) error {
+	root := r.ReportTree()
+
+	suiteName := root.Test
+	if suiteName == "" {
+		suiteName = "frame2"
+	}
+	suite := junitTestSuite{
+		Name:      suiteName,
+		Time:      junitSeconds(root.totalDuration()),
+		Timestamp: root.firstStart().Format(time.RFC3339),
+	}
+	var addCases func(n *ReportNode, parentFailed bool)
+	addCases = func(n *ReportNode, parentFailed bool) {
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%s %s", n.Id, n.Title()),
+			ClassName: n.Test,
+			Time:      junitSeconds(n.Duration),
+			SystemOut: n.systemOut(),
+		}
+		if skip, ok := AsSkip(n.Err); ok {
+			tc.Skipped = &junitSkipped{Message: skip.Reason}
+			suite.Skipped++
+		} else if n.Err != nil {
+			if parentFailed {
+				tc.Failure = &junitFailure{
+					Message: n.Err.Error(),
+					Text:    n.Err.Error(),
+				}
+				suite.Failures++
+			} else {
+				tc.SystemOut = strings.TrimSpace(fmt.Sprintf("handled error: %v\n%s", n.Err, tc.SystemOut))
+			}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+		for _, c := range n.Children {
+			addCases(c, parentFailed && n.Failed())
+		}
+	}
+	for _, c := range root.Children {
+		addCases(c, true)
+	}
+
+	suites := junitTestSuites{
+		Suites: []junitTestSuite{suite},
+	}
+
+	if monitors := r.getRoot().monitorResults; len(monitors) > 0 {
+		mSuite := junitTestSuite{
+			Name: suiteName + "/monitors",
+			Time: junitSeconds(0),
+		}
+		for i, m := range monitors {
+			tc := junitTestCase{
+				Name:      fmt.Sprintf("M%d %s", i, m.name),
+				ClassName: suiteName,
+				Time:      junitSeconds(0),
+			}
+			if m.err != nil {
+				tc.Failure = &junitFailure{Message: m.err.Error(), Text: m.err.Error()}
+				mSuite.Failures++
+			}
+			mSuite.Tests++
+			mSuite.Cases = append(mSuite.Cases, tc)
+		}
+		suites.Suites = append(suites.Suites, mSuite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Calls fn for n and each of its descendants, depth first
+func (n *ReportNode) walk(fn func(*ReportNode)) {
+	fn(n)
+	for _, c := range n.Children {
+		c.walk(fn)
+	}
+}
+
+func (n *ReportNode) firstStart() time.Time {
+	var first time.Time
+	n.walk(func(c *ReportNode) {
+		if !c.Start.IsZero() && (first.IsZero() || c.Start.Before(first)) {
+			first = c.Start
+		}
+	})
+	return first
+}
+
+func (n *ReportNode) totalDuration() time.Duration {
+	first := n.firstStart()
+	var last time.Time
+	n.walk(func(c *ReportNode) {
+		if end := c.Start.Add(c.Duration); end.After(last) {
+			last = end
+		}
+	})
+	if first.IsZero() {
+		return 0
+	}
+	return last.Sub(first)
+}
+
+func (n *ReportNode) systemOut() string {
+	var lines []string
+	if n.Frame != "" {
+		lines = append(lines, fmt.Sprintf("frame: %s", n.Frame))
+	}
+	if n.Attempts > 1 {
+		lines = append(lines, fmt.Sprintf("attempts: %d", n.Attempts))
+	}
+	lines = append(lines, n.Log...)
+	return strings.Join(lines, "\n")
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"seconds": func(d time.Duration) string { return fmt.Sprintf("%.3fs", d.Seconds()) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Root.Test}}</title>
+<style>
+body { font-family: sans-serif; }
+details { margin-left: 1.5em; }
+summary { cursor: pointer; }
+.ok { color: #1a7f37; }
+.failed { color: #cf222e; font-weight: bold; }
+.skipped { color: #9a6700; }
+.id { font-family: monospace; color: #57606a; }
+.time { color: #57606a; }
+pre { background: #f6f8fa; padding: 0.5em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Root.Test}}</h1>
+{{with .Root.Doc}}<p>{{.}}</p>{{end}}
+<p>Execution {{.Id}}, generated at {{.Generated}}; steps: {{.Steps}}</p>
+{{range .Root.Children}}{{template "node" .}}{{end}}
+{{with .Monitors}}
+<h2>Monitors</h2>
+<ul>
+{{range .}}<li class="{{if .Err}}failed{{else}}ok{{end}}">{{.Name}}{{with .Err}}: {{.}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+{{define "node"}}<details{{if .Failed}} open{{end}}>
+<summary><span class="id">{{.Id}}</span> <span class="{{if .Failed}}failed{{else if .Skipped}}skipped{{else}}ok{{end}}">{{.Title}}</span> <span class="time">({{seconds .Duration}}{{if gt .Attempts 1}}, {{.Attempts}} attempts{{end}})</span></summary>
+{{with .Frame}}<div>Frame: <code>{{.}}</code></div>{{end}}
+{{with .Err}}<div class="{{if $.Skipped}}skipped{{else}}failed{{end}}">{{.}}</div>{{end}}
+{{with .Log}}<pre>{{range .}}{{.}}
+{{end}}</pre>{{end}}
+{{range .Children}}{{template "node" .}}{{end}}
+</details>
+{{end}}`))
+
+// The result of a monitor's Report, as seen by Run.Report
+type monitorResult struct {
+	name string
+	err  error
+}
+
+type htmlMonitor struct {
+	Name string
+	Err  error
+}
+
+// Writes the report tree of the test as a self-contained HTML page, with
+// a collapsible tree of the Runners.  Failed branches start expanded
+func (r *Run) WriteHTML(w io.Writer) error {
+	data := struct {
+		Root      *ReportNode
+		Id        string
+		Generated string
+		Monitors  []htmlMonitor
+		Steps     StepCounts
+	}{
+		Root:      r.ReportTree(),
+		Id:        GetId(),
+		Generated: time.Now().Format(time.RFC3339),
+	}
+	data.Steps = data.Root.StepCounts()
+	for i, m := range r.getRoot().monitorResults {
+		data.Monitors = append(data.Monitors, htmlMonitor{
+			Name: fmt.Sprintf("M%d %s", i, m.name),
+			Err:  m.err,
+		})
+	}
+	return htmlReport.Execute(w, data)
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Writes both the JUnit and the HTML reports to the given directory, on
+// files named after the test
+func (r *Run) WriteReports(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := "frame2"
+	if root := r.getRoot(); root.T != nil {
+		name = root.T.Name()
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%s-%s", unsafeFileChars.ReplaceAllString(name, "_"), GetShortId()))
+
+	for ext, writer := range map[string]func(io.Writer) error{
+		".xml":  r.WriteJUnit,
+		".html": r.WriteHTML,
+	} {
+		f, err := os.Create(base + ext)
+		if err != nil {
+			return err
+		}
+		err = writer(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed writing report %q: %w", base+ext, err)
+		}
+		r.logf(LevelInfo, "[R] Report written to %q", base+ext)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package frame2_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"gotest.tools/assert"
+)
+
+type junitResult struct {
+	Suites []struct {
+		Name     string `xml:"name,attr"`
+		Tests    int    `xml:"tests,attr"`
+		Failures int    `xml:"failures,attr"`
+		Cases    []struct {
+			Name      string    `xml:"name,attr"`
+			Failure   *struct{} `xml:"failure"`
+			SystemOut string    `xml:"system-out"`
+		} `xml:"testcase"`
+	} `xml:"testsuite"`
+}
+
+func TestReport(t *testing.T) {
+	runner := &frame2.Run{
+		T:   t,
+		Doc: "Report generation",
+	}
+
+	p := frame2.Phase{
+		Runner: runner,
+		Name:   "report",
+		MainSteps: []frame2.Step{
+			{
+				Name: "expected-failure",
+				Doc:  "This validator fails, but that's expected",
+				Validator: &f2general.Fail{
+					Reason: "expected",
+				},
+				ExpectError: true,
+			}, {
+				Doc: "This one succeeds on the second attempt",
+				Validator: &f2general.Dummy{
+					Results: []error{errors.New("first attempt"), nil},
+				},
+				ValidatorRetry: frame2.RetryOptions{
+					Allow:    1,
+					Interval: 1,
+				},
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+
+	var buf bytes.Buffer
+	assert.Assert(t, runner.WriteJUnit(&buf))
+
+	var result junitResult
+	assert.Assert(t, xml.Unmarshal(buf.Bytes(), &result))
+	assert.Equal(t, len(result.Suites), 1)
+	suite := result.Suites[0]
+	assert.Equal(t, suite.Name, "TestReport")
+	assert.Assert(t, suite.Tests > 0)
+	assert.Equal(t, suite.Failures, 0, "handled errors should not be reported as failures:\n%s", buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), "attempts: 2"), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), "expected-failure"), buf.String())
+
+	buf.Reset()
+	assert.Assert(t, runner.WriteHTML(&buf))
+	assert.Assert(t, strings.Contains(buf.String(), "<details"))
+	assert.Assert(t, strings.Contains(buf.String(), "Report generation"))
+}
+
+func TestReportFailure(t *testing.T) {
+	// No testing.T, so the failure does not fail this test
+	runner := &frame2.Run{}
+
+	p := frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			{
+				Doc:    "This one fails",
+				Modify: &f2general.Fail{Reason: "for the report"},
+			},
+		},
+	}
+	assert.Assert(t, p.Run() != nil)
+
+	var buf bytes.Buffer
+	assert.Assert(t, runner.WriteJUnit(&buf))
+
+	var result junitResult
+	assert.Assert(t, xml.Unmarshal(buf.Bytes(), &result))
+	assert.Assert(t, result.Suites[0].Failures > 0, buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), "for the report"), buf.String())
+}
@@ -2,19 +2,95 @@ package frame2
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 )
 
 type RetryFunction func() (err error)
 
+type permanentError struct {
+	err error
+}
+
+func (p permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p permanentError) Unwrap() error {
+	return p.err
+}
+
+// Marks err as permanent: a failure that will never recover, such as a
+// configuration error.  Retry.Run stops on those right away, instead of
+// using up its retries and timeouts.
+//
+// The mark survives wrapping with %w.  Permanent(nil) returns nil
+func Permanent(err error) error {
+	if err == nil || IsPermanent(err) {
+		return err
+	}
+	return permanentError{err: err}
+}
+
+// Whether err, or any error it wraps, was marked with Permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Decides which errors are permanent, in addition to those marked with
+// Permanent.  See RetryOptions.Classifier
+type ErrorClassifier interface {
+	Permanent(err error) bool
+}
+
+// Allows a function to be used as an ErrorClassifier
+type ErrorClassifierFunc func(err error) bool
+
+func (f ErrorClassifierFunc) Permanent(err error) bool {
+	return f(err)
+}
+
+// An error is permanent if either classifier says so; used by
+// RetryOptions.Max
+type eitherClassifier struct {
+	a, b ErrorClassifier
+}
+
+func (e eitherClassifier) Permanent(err error) bool {
+	return e.a.Permanent(err) || e.b.Permanent(err)
+}
+
 type Retry struct {
 	Fn      RetryFunction // The thing to be retried
 	Options RetryOptions
 }
 
+// How the interval between attempts changes along a Retry
+type Backoff int
+
+const (
+	// Always wait Interval (the default)
+	BackoffConstant Backoff = iota
+	// Wait Interval * n after the nth attempt
+	BackoffLinear
+	// Double the wait after each attempt, starting with Interval
+	BackoffExponential
+	// Like BackoffExponential, but each wait is randomly reduced by up to
+	// a half, so that concurrent retries do not synchronize
+	BackoffJitteredExponential
+)
+
+// The cap for the interval of growing Backoff strategies, if no
+// RetryOptions.MaxInterval is given
+const DefaultMaxInterval = time.Minute
+
 type rootContexts []context.CancelFunc
 type rootContextType int
 
@@ -49,20 +125,39 @@ type RetryOptions struct {
 	Ensure   int           // last n tries are successful.  Minimum 1
 	Retries  int           // after Allow phase
 	Interval time.Duration // if not given, the default is 1s
-	Quiet    bool          // if true, no attempt logs
+	// Backoff strategy; the default is a constant Interval
+	Backoff Backoff
+	// Cap for the interval on growing Backoff strategies.  If not given,
+	// DefaultMaxInterval is used for those
+	MaxInterval time.Duration
+	Quiet       bool // if true, no attempt logs
 	//	Context     bool // aggregate timed with number of tries; either or both can be used
 	//	Verbose     bool // Log every error?
 
-	Min int // TODO Run as normal, but delay report until that number of tries have been done
-	// This can be used to generate stats from the results
+	Min int // Run as normal, but delay report until that number of tries have been done
+	// This can be used to generate stats from the results.  The verdict is
+	// the first one reached; the additional tries do not change it
 
-	Rate float32 // TODO: "Ensure" will not be 100%, but based on this rate.  So, if Ensure is 100
+	Rate float32 // "Ensure" will not be 100%, but based on this rate.  So, if Ensure is 100
 	// And Rate 50%, success will be achieved with at least 50 success in the past
-	// 100 executions
+	// 100 executions.  Rate is a ratio (0.5), but values above 1 are taken as
+	// percentages (50).
+	//
+	// On this mode, a failure is only declared once the window of the last
+	// Ensure attempts cannot reach the Rate anymore; each Retry then starts a
+	// new window.
 
 	KeepTrying bool
 	Ctx        context.Context
 	Timeout    time.Duration
+
+	// These are only used by Retry.ParallelRun
+	Workers     int // Number of concurrent workers; each follows all options above.  Minimum 1
+	Concurrency int // Maximum concurrent calls to Fn across all workers.  If zero, same as Workers
+
+	// Errors it classifies as permanent stop the retries immediately, like
+	// those marked with Permanent
+	Classifier ErrorClassifier
 }
 
 // Returns a new RetryOptions, whose values are the maximum between r and other.
@@ -92,6 +187,13 @@ func (r RetryOptions) Max(other RetryOptions) (RetryOptions, context.CancelFunc)
 	if other.Interval.Seconds() > r.Interval.Seconds() {
 		r.Interval = other.Interval
 	}
+	// The strategies are ordered from the most to the least aggressive
+	if other.Backoff > r.Backoff {
+		r.Backoff = other.Backoff
+	}
+	if other.MaxInterval > r.MaxInterval {
+		r.MaxInterval = other.MaxInterval
+	}
 	if other.Min > r.Min {
 		r.Min = other.Min
 	}
@@ -107,6 +209,18 @@ func (r RetryOptions) Max(other RetryOptions) (RetryOptions, context.CancelFunc)
 	if other.Timeout.Seconds() > r.Timeout.Seconds() {
 		r.Timeout = other.Timeout
 	}
+	if other.Workers > r.Workers {
+		r.Workers = other.Workers
+	}
+	if other.Concurrency > r.Concurrency {
+		r.Concurrency = other.Concurrency
+	}
+	switch {
+	case r.Classifier == nil:
+		r.Classifier = other.Classifier
+	case other.Classifier != nil:
+		r.Classifier = eitherClassifier{r.Classifier, other.Classifier}
+	}
 	// context merge
 	// TODO: change to context.AfterFunc, when moving to 1.21+
 	// Also, move this elsewhere, for reuse, or find some library that
@@ -192,30 +306,107 @@ func (r RetryOptions) IsEmpty() bool {
 		r.Ensure != 0 ||
 		r.Retries != 0 ||
 		r.Interval != 0 ||
+		r.Backoff != BackoffConstant ||
+		r.MaxInterval != 0 ||
 		r.Quiet != false ||
 		r.Min != 0 ||
 		r.Rate != 0 ||
 		r.KeepTrying != false ||
 		r.Ctx != nil ||
-		r.Timeout != 0 {
+		r.Timeout != 0 ||
+		r.Workers != 0 ||
+		r.Concurrency != 0 ||
+		r.Classifier != nil {
 		return false
 	}
 
 	return true
 }
 
-func (r Retry) Run() ([]error, error) {
-	interval := r.Options.Interval
+// Whether the error should stop the retries right away
+func (r RetryOptions) isPermanent(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsPermanent(err) {
+		return true
+	}
+	return r.Classifier != nil && r.Classifier.Permanent(err)
+}
+
+// Returns how long to wait after the given attempt (starting at 1), per
+// the Backoff strategy
+func (r RetryOptions) delay(attempt int) time.Duration {
+	interval := r.Interval
 	if interval == 0 {
 		interval = time.Second
 	}
+	maxInterval := r.MaxInterval
+	if maxInterval == 0 && r.Backoff != BackoffConstant {
+		maxInterval = DefaultMaxInterval
+	}
+
+	var d time.Duration
+	switch r.Backoff {
+	case BackoffConstant:
+		d = interval
+	case BackoffLinear:
+		d = interval * time.Duration(attempt)
+	case BackoffExponential, BackoffJitteredExponential:
+		d = interval
+		for i := 1; i < attempt && d < maxInterval; i++ {
+			d *= 2
+		}
+	default:
+		panic(fmt.Sprintf("unknown Backoff strategy %d", r.Backoff))
+	}
+	if maxInterval > 0 && d > maxInterval {
+		d = maxInterval
+	}
+	if r.Backoff == BackoffJitteredExponential {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	return d
+}
+
+// Waits until the delay for the given attempt has passed since its
+// start, or until the context is done
+func (r RetryOptions) wait(ctx context.Context, attemptStart time.Time, attempt int) {
+	timer := time.NewTimer(time.Until(attemptStart.Add(r.delay(attempt))))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Returns Rate as a ratio between 0 and 1 (or 0, if not set)
+func (r RetryOptions) normalizedRate() float64 {
+	rate := float64(r.Rate)
+	if rate > 1 {
+		rate = rate / 100
+	}
+	if rate > 1 {
+		rate = 1
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	return rate
+}
 
-	tick := time.NewTicker(interval)
-	defer tick.Stop()
+func successRatio(successes, total int) string {
+	if total == 0 {
+		return "0/0 successes"
+	}
+	return fmt.Sprintf("%d/%d successes (%.1f%%)", successes, total, float64(successes)/float64(total)*100)
+}
 
+func (r Retry) Run() ([]error, error) {
 	results := []error{}
 
 	var totalTries int
+	var totalSuccesses int
 	var consecutiveSuccess int
 	var ignoredSuccess int
 	var retries int
@@ -225,6 +416,33 @@ func (r Retry) Run() ([]error, error) {
 	if ensure < 1 {
 		ensure = 1
 	}
+
+	// On Rate mode, the success is evaluated on a window of the last
+	// Ensure counted attempts
+	rate := r.Options.normalizedRate()
+	var window []bool
+	needed := int(math.Ceil(rate * float64(ensure)))
+
+	// With Min, a verdict reached too early is kept here until Min
+	// attempts have been done
+	var decided bool
+	var verdict error
+	// Returns true if the verdict can be returned right away
+	conclude := func(v error) bool {
+		if totalTries >= r.Options.Min {
+			return true
+		}
+		decided = true
+		verdict = v
+		if !r.Options.Quiet {
+			log.Printf(
+				"Verdict reached on attempt %d (%v); continuing until %d attempts (Min)",
+				totalTries, v, r.Options.Min,
+			)
+		}
+		return false
+	}
+
 	ctx := r.Options.Ctx
 	if ctx == nil {
 		ctx = context.Background()
@@ -238,6 +456,9 @@ func (r Retry) Run() ([]error, error) {
 		// Before any tries, check the context
 		err := ctx.Err()
 		if err != nil {
+			if decided {
+				return results, verdict
+			}
 			if !r.Options.Quiet {
 				log.Printf("retry cancelled: %v", err)
 			}
@@ -245,12 +466,125 @@ func (r Retry) Run() ([]error, error) {
 		}
 
 		totalTries++
+		attemptStart := time.Now()
 		err = r.Fn()
 		results = append(results, err)
+		if err == nil {
+			totalSuccesses++
+		}
 		var contextInfo string
 		if dl, ok := ctx.Deadline(); ok {
 			contextInfo = fmt.Sprintf(" [timeout in %v]", dl.Sub(time.Now()))
 		}
+
+		if decided {
+			// We're only running to complete Min; the result does not
+			// change the verdict, but a permanent error ends the attempts
+			if r.Options.isPermanent(err) {
+				if !r.Options.Quiet {
+					log.Printf("Attempt %d (for Min %d) failed with a permanent error; keeping the verdict: %v", totalTries, r.Options.Min, err)
+				}
+				return results, verdict
+			}
+			if totalTries >= r.Options.Min {
+				if !r.Options.Quiet {
+					log.Printf("Completed %d attempts (Min): %s", totalTries, successRatio(totalSuccesses, totalTries))
+				}
+				return results, verdict
+			}
+			if !r.Options.Quiet {
+				log.Printf(
+					"Attempt %d (for Min %d): %v; %s",
+					totalTries, r.Options.Min, err, successRatio(totalSuccesses, totalTries),
+				)
+			}
+			r.Options.wait(ctx, attemptStart, totalTries)
+			continue
+		}
+
+		if r.Options.isPermanent(err) {
+			if !r.Options.Quiet {
+				log.Printf("Attempt %d failed with a permanent error; not retrying: %v", totalTries, err)
+			}
+			return results, err
+		}
+
+		if rate > 0 {
+			if err == nil {
+				if ignoredSuccess >= r.Options.Ignore || totalTries > r.Options.Ignore {
+					window = append(window, true)
+				} else {
+					ignoredSuccess++
+				}
+			} else {
+				if totalTries <= r.Options.Allow {
+					// Failures on the Allow phase are not counted; like in
+					// the streak mode, only the last successes count
+					window = window[:0]
+				} else {
+					window = append(window, false)
+				}
+			}
+			if len(window) > ensure {
+				window = window[1:]
+			}
+			var windowSuccesses int
+			for _, w := range window {
+				if w {
+					windowSuccesses++
+				}
+			}
+			if len(window) == ensure && windowSuccesses >= needed {
+				if totalTries > 1 {
+					log.Printf(
+						"Success on attempt %v: %d/%d on the last attempts (rate %.1f%%); %s",
+						totalTries, windowSuccesses, ensure, rate*100, successRatio(totalSuccesses, totalTries),
+					)
+				}
+				if conclude(nil) {
+					return results, nil
+				}
+				r.Options.wait(ctx, attemptStart, totalTries)
+				continue
+			}
+			// Can this window still reach the rate?
+			if len(window)-windowSuccesses > ensure-needed {
+				if !r.Options.KeepTrying && retries >= r.Options.Retries {
+					rateErr := fmt.Errorf(
+						"success rate below %.1f%% (%d/%d on the last attempts): %w",
+						rate*100, windowSuccesses, len(window), err,
+					)
+					if conclude(rateErr) {
+						return results, rateErr
+					}
+					r.Options.wait(ctx, attemptStart, totalTries)
+					continue
+				}
+				// Start a new evaluation window
+				if !r.Options.KeepTrying {
+					retries++
+				}
+				window = window[:0]
+			}
+			if !r.Options.Quiet {
+				msg := fmt.Sprintf(
+					"Attempt %d %s; %d/%d on the window (need %d); %d/%d retries used; %s",
+					totalTries,
+					map[bool]string{true: "succeeded", false: "failed"}[err == nil],
+					windowSuccesses, len(window), needed,
+					retries, r.Options.Retries,
+					successRatio(totalSuccesses, totalTries),
+				)
+				if r.Options.KeepTrying {
+					msg += " [keep trying]"
+				}
+				msg += contextInfo
+				log.Print(msg)
+			}
+			r.Options.wait(ctx, attemptStart, totalTries)
+			continue
+		}
+
 		if err == nil {
 			// Are we counting this as a success?
 			if ignoredSuccess >= r.Options.Ignore || totalTries > r.Options.Ignore {
@@ -261,9 +595,13 @@ func (r Retry) Run() ([]error, error) {
 			// Are we good?
 			if consecutiveSuccess >= ensure {
 				if totalTries > 1 {
-					log.Printf("Success on attempt %v", totalTries)
+					log.Printf("Success on attempt %v; %s", totalTries, successRatio(totalSuccesses, totalTries))
 				}
-				return results, nil
+				if conclude(nil) {
+					return results, nil
+				}
+				r.Options.wait(ctx, attemptStart, totalTries)
+				continue
 			}
 			// It's a success, but not enough; we'll try again
 			if !r.Options.Quiet {
@@ -274,6 +612,7 @@ func (r Retry) Run() ([]error, error) {
 				if r.Options.Ignore > 0 {
 					info = append(info, fmt.Sprintf("%d/%d ignored", ignoredSuccess, r.Options.Ignore))
 				}
+				info = append(info, successRatio(totalSuccesses, totalTries))
 				info = append(info, contextInfo)
 
 				msg := fmt.Sprintf("Attempt %d succeeded; ", totalTries)
@@ -282,18 +621,20 @@ func (r Retry) Run() ([]error, error) {
 
 				log.Printf(msg)
 			}
-			<-tick.C
+			r.Options.wait(ctx, attemptStart, totalTries)
 			continue
 		}
 		if !r.Options.KeepTrying {
 			// This try failed, and we ran out of retries.  Note retries only count after Allow expires
 			if totalTries > r.Options.Allow && retries >= r.Options.Retries {
 				if r.Options.Retries > 1 {
-					return results, fmt.Errorf("max retry attempts reached: %w", err)
-				} else {
+					err = fmt.Errorf("max retry attempts reached: %w", err)
+				}
+				if conclude(err) {
 					return results, err
 				}
-
+				r.Options.wait(ctx, attemptStart, totalTries)
+				continue
 			}
 		}
 		consecutiveSuccess = 0
@@ -304,8 +645,9 @@ func (r Retry) Run() ([]error, error) {
 		}
 		if !r.Options.Quiet {
 			msg := fmt.Sprintf(
-				"Attempt %d failed (allow %d first + %d/%d retries used)",
+				"Attempt %d failed (allow %d first + %d/%d retries used); %s",
 				totalTries, r.Options.Allow, retries, r.Options.Retries,
+				successRatio(totalSuccesses, totalTries),
 			)
 			if r.Options.KeepTrying {
 				msg += " [keep trying]"
@@ -313,14 +655,80 @@ func (r Retry) Run() ([]error, error) {
 			msg += contextInfo
 			log.Print(msg)
 		}
-		<-tick.C
+		r.Options.wait(ctx, attemptStart, totalTries)
 	}
 }
 
 // Runs the retry in parallel; returns a function
 // that will wait and return the results only
 // when it finished (wait).
-// TODO perhaps give it a context, too
-func (r Retry) ParallelRun() func() []error {
-	return nil
+//
+// Options.Workers workers are started, and each of them runs Fn under
+// the same semantics as Retry.Run, with the whole of the options (Allow,
+// Ensure, Retries, etc).  The calls to Fn across all workers are limited
+// to Options.Concurrency at a time.
+//
+// Options.Timeout and Options.Ctx are shared by all workers: the timeout
+// counts from the call to ParallelRun, not from each worker's start.
+//
+// The returned function must always be called.  Its []error contains the
+// result of every call to Fn, in the order they finished.  Its error is
+// nil only if all workers succeeded; otherwise, it joins the errors of
+// the failed workers.
+func (r Retry) ParallelRun() func() ([]error, error) {
+	workers := r.Options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	limit := r.Options.Concurrency
+	if limit < 1 || limit > workers {
+		limit = workers
+	}
+
+	opts := r.Options
+	ctx := ContextOrDefault(opts.Ctx)
+	cancel := func() {}
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		opts.Timeout = 0
+	}
+	opts.Ctx = ctx
+
+	sem := make(chan struct{}, limit)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	results := []error{}
+	workerErrors := make([]error, workers)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := Retry{
+				Options: opts,
+				Fn: func() error {
+					select {
+					case sem <- struct{}{}:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+					err := r.Fn()
+					<-sem
+					lock.Lock()
+					results = append(results, err)
+					lock.Unlock()
+					return err
+				},
+			}.Run()
+			if err != nil {
+				workerErrors[i] = fmt.Errorf("worker %d: %w", i, err)
+			}
+		}(i)
+	}
+
+	return func() ([]error, error) {
+		wg.Wait()
+		cancel()
+		return results, errors.Join(workerErrors...)
+	}
 }
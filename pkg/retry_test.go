@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -334,6 +335,91 @@ func TestRetry(t *testing.T) {
 					result: funcError,
 				},
 			},
+		}, {
+			// Rate: 3 successes on the last 4 attempts
+			config: RetryOptions{
+				Ensure:   4,
+				Rate:     0.75,
+				Interval: time.Millisecond,
+			},
+			checks: []testChecks{
+				{
+					input:  []error{nil, nil, nil, nil},
+					result: nil,
+				}, {
+					input:  []error{nil, funcError, nil, nil},
+					result: nil,
+				}, {
+					// The second failure makes the rate unreachable
+					input:  []error{funcError, nil, funcError},
+					result: funcError,
+				},
+			},
+		}, {
+			// Rate as a percentage, with a retry (a new window)
+			config: RetryOptions{
+				Ensure:   4,
+				Rate:     75,
+				Retries:  1,
+				Interval: time.Millisecond,
+			},
+			checks: []testChecks{
+				{
+					input:  []error{funcError, funcError, nil, nil, funcError, nil},
+					result: nil,
+				}, {
+					input:  []error{funcError, funcError, funcError, funcError},
+					result: funcError,
+				},
+			},
+		}, {
+			// Rate with Allow: failures on the Allow phase are not counted
+			config: RetryOptions{
+				Allow:    2,
+				Ensure:   2,
+				Rate:     0.5,
+				Interval: time.Millisecond,
+			},
+			checks: []testChecks{
+				{
+					input:  []error{funcError, funcError, nil, funcError},
+					result: nil,
+				}, {
+					input:  []error{nil, funcError, funcError, funcError},
+					result: funcError,
+				},
+			},
+		}, {
+			// Min: the verdict is delayed, but not changed
+			config: RetryOptions{
+				Min:      3,
+				Interval: time.Millisecond,
+			},
+			checks: []testChecks{
+				{
+					input:  []error{nil, funcError, funcError},
+					result: nil,
+				}, {
+					input:  []error{funcError, nil, nil},
+					result: funcError,
+				}, {
+					input:  []error{nil, nil, nil},
+					result: nil,
+				},
+			},
+		}, {
+			config: RetryOptions{
+				Min:      2,
+				Retries:  3,
+				Interval: time.Millisecond,
+			},
+			checks: []testChecks{
+				{
+					// Min reached before the verdict
+					input:  []error{funcError, funcError, nil},
+					result: nil,
+				},
+			},
 		},
 	}
 
@@ -564,3 +650,212 @@ func TestRetryOptMax(t *testing.T) {
 	assert.Assert(t, optC1C2C3C4.Ctx.Err() != nil, "cancelling a member cancels the merged context")
 
 }
+
+func TestParallelRun(t *testing.T) {
+	var calls, running, maxRunning int32
+
+	wait := Retry{
+		Options: RetryOptions{
+			Workers:     6,
+			Concurrency: 2,
+			Ensure:      3,
+			Interval:    time.Millisecond,
+		},
+		Fn: func() error {
+			atomic.AddInt32(&calls, 1)
+			now := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				current := atomic.LoadInt32(&maxRunning)
+				if now <= current || atomic.CompareAndSwapInt32(&maxRunning, current, now) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		},
+	}.ParallelRun()
+
+	results, err := wait()
+	assert.Assert(t, err)
+	assert.Equal(t, len(results), 18, "each of the 6 workers should ensure 3 successes")
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(18))
+	assert.Assert(t, atomic.LoadInt32(&maxRunning) <= 2, "concurrency limit not respected: %d", maxRunning)
+
+	// Failures are reported per worker
+	var n int32
+	wait = Retry{
+		Options: RetryOptions{
+			Workers:  3,
+			Retries:  1,
+			Interval: time.Millisecond,
+		},
+		Fn: func() error {
+			// Only the first call succeeds
+			if atomic.AddInt32(&n, 1) == 1 {
+				return nil
+			}
+			return funcError
+		},
+	}.ParallelRun()
+	results, err = wait()
+	assert.Assert(t, errors.Is(err, funcError))
+	assert.Equal(t, len(results), 5, "one success, plus two workers with two failed attempts each")
+
+	// The timeout is shared by all workers
+	start := time.Now()
+	wait = Retry{
+		Options: RetryOptions{
+			Workers:    3,
+			KeepTrying: true,
+			Interval:   time.Millisecond,
+			Timeout:    50 * time.Millisecond,
+		},
+		Fn: func() error {
+			return funcError
+		},
+	}.ParallelRun()
+	_, err = wait()
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Assert(t, time.Since(start) < 5*time.Second)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	interval := 10 * time.Millisecond
+
+	constant := RetryOptions{Interval: interval}
+	linear := RetryOptions{Interval: interval, Backoff: BackoffLinear}
+	exponential := RetryOptions{Interval: interval, Backoff: BackoffExponential, MaxInterval: 50 * time.Millisecond}
+	for attempt, expected := range map[int][3]time.Duration{
+		1: {interval, interval, interval},
+		2: {interval, 2 * interval, 2 * interval},
+		3: {interval, 3 * interval, 4 * interval},
+		4: {interval, 4 * interval, 5 * interval},
+		// Large attempt numbers do not overflow
+		100: {interval, 100 * interval, 5 * interval},
+	} {
+		assert.Equal(t, constant.delay(attempt), expected[0], "constant, attempt %d", attempt)
+		assert.Equal(t, linear.delay(attempt), expected[1], "linear, attempt %d", attempt)
+		assert.Equal(t, exponential.delay(attempt), expected[2], "exponential, attempt %d", attempt)
+	}
+
+	// Without a MaxInterval, growing strategies are capped by the default
+	assert.Equal(t, RetryOptions{Interval: interval, Backoff: BackoffExponential}.delay(100), DefaultMaxInterval)
+
+	jittered := RetryOptions{Interval: interval, Backoff: BackoffJitteredExponential, MaxInterval: 40 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		d := jittered.delay(3)
+		assert.Assert(t, d >= 20*time.Millisecond && d <= 40*time.Millisecond, "jittered delay out of range: %v", d)
+	}
+
+	// The most conservative strategy wins on Max
+	merged, mergedCancel := linear.Max(exponential)
+	defer mergedCancel()
+	assert.Equal(t, merged.Backoff, BackoffExponential)
+	assert.Equal(t, merged.MaxInterval, 50*time.Millisecond)
+	assert.Assert(t, !RetryOptions{Backoff: BackoffLinear}.IsEmpty())
+	assert.Assert(t, !RetryOptions{MaxInterval: time.Second}.IsEmpty())
+
+	// A cancelled context interrupts the wait
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	RetryOptions{Interval: time.Hour}.wait(ctx, start, 1)
+	assert.Assert(t, time.Since(start) < time.Second)
+
+	// Run works with a growing backoff
+	var calls int
+	results, err := Retry{
+		Options: RetryOptions{
+			Allow:    3,
+			Interval: time.Millisecond,
+			Backoff:  BackoffExponential,
+		},
+		Fn: func() error {
+			calls++
+			if calls < 3 {
+				return funcError
+			}
+			return nil
+		},
+	}.Run()
+	assert.Assert(t, err)
+	assert.Equal(t, len(results), 3)
+}
+
+func TestRetryPermanent(t *testing.T) {
+	permanent := Permanent(funcError)
+	assert.Assert(t, IsPermanent(permanent))
+	assert.Assert(t, IsPermanent(fmt.Errorf("wrapped: %w", permanent)))
+	assert.Assert(t, errors.Is(permanent, funcError))
+	assert.Assert(t, !IsPermanent(funcError))
+	assert.Assert(t, Permanent(nil) == nil)
+
+	// A permanent error stops the retries right away
+	var calls int
+	results, err := Retry{
+		Options: RetryOptions{
+			Allow:    10,
+			Interval: time.Millisecond,
+		},
+		Fn: func() error {
+			calls++
+			if calls == 2 {
+				return fmt.Errorf("on call %d: %w", calls, permanent)
+			}
+			return funcError
+		},
+	}.Run()
+	assert.Assert(t, IsPermanent(err))
+	assert.Equal(t, len(results), 2)
+
+	// As does any error the classifier considers permanent
+	calls = 0
+	results, err = Retry{
+		Options: RetryOptions{
+			KeepTrying: true,
+			Interval:   time.Millisecond,
+			Classifier: ErrorClassifierFunc(func(err error) bool {
+				return errors.Is(err, funcError)
+			}),
+		},
+		Fn: func() error {
+			calls++
+			return funcError
+		},
+	}.Run()
+	assert.Assert(t, errors.Is(err, funcError))
+	assert.Equal(t, len(results), 1)
+
+	// Once the verdict is reached, a permanent error while running up to
+	// Min does not replace it; it only ends the attempts
+	calls = 0
+	results, err = Retry{
+		Options: RetryOptions{
+			Min:      5,
+			Interval: time.Millisecond,
+			Quiet:    true,
+		},
+		Fn: func() error {
+			calls++
+			if calls == 2 {
+				return permanent
+			}
+			return nil
+		},
+	}.Run()
+	assert.Assert(t, err)
+	assert.Equal(t, len(results), 2)
+
+	// Classifiers are combined by Max
+	other := errors.New("other")
+	merged, cancel := RetryOptions{
+		Classifier: ErrorClassifierFunc(func(err error) bool { return errors.Is(err, funcError) }),
+	}.Max(RetryOptions{
+		Classifier: ErrorClassifierFunc(func(err error) bool { return errors.Is(err, other) }),
+	})
+	defer cancel()
+	assert.Assert(t, merged.isPermanent(funcError))
+	assert.Assert(t, merged.isPermanent(other))
+	assert.Assert(t, !merged.isPermanent(errors.New("transient")))
+}
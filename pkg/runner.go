@@ -2,21 +2,19 @@ package frame2
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"runtime/debug"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
 )
 
-// TODO: Uniformize use of contexts; create main context that has a deadline
-// one minute before the maximum test time, if that's equal or greater than the
-// default 10m timeout
-//
 // TODO: Uniformize Validator and Executor requirements.  Put it all in an
 // embeddable struct (Log, Context, Runner, etc)
 
@@ -36,8 +34,38 @@ const (
 	StepRunner
 	TearDownRunner
 	MonitorRunner
+	ParallelRunner
 )
 
+func (k RunnerType) String() string {
+	switch k {
+	case RootRunner:
+		return "root"
+	case PhaseRunner:
+		return "phase"
+	case ValidatorRunner:
+		return "validator"
+	case ModifyRunner:
+		return "modify"
+	case SetupRunner:
+		return "setup"
+	case HookRunner:
+		return "hook"
+	case SubTestRunner:
+		return "subtest"
+	case StepRunner:
+		return "step"
+	case TearDownRunner:
+		return "teardown"
+	case MonitorRunner:
+		return "monitor"
+	case ParallelRunner:
+		return "parallel"
+	default:
+		return fmt.Sprintf("RunnerType(%d)", int(k))
+	}
+}
+
 var spewer = spew.ConfigState{
 	Indent:                  " ",
 	MaxDepth:                4,
@@ -54,9 +82,15 @@ var spewer = spew.ConfigState{
 // phase and each step has its runner, in a tree structure
 type Run struct {
 	T                  *testing.T
-	Doc                string      // TODO this is currently unused (ie, it's not printed)
+	Doc                string      // Describes the test; see WriteDoc
 	RequiredDisruptors []Disruptor // TODO
-	savedT             *testing.T  // TODO: review.  Only private + getter/setter?
+
+	// How long Step and Phase timeouts wait for their frames to return
+	// after the context is done, before abandoning them; one second if
+	// zero.  Only the root Run's is used
+	TimeoutGrace time.Duration
+
+	savedT             *testing.T // TODO: review.  Only private + getter/setter?
 	monitors           []*Monitor
 	finalValidators    []Validator
 	subFinalValidators []Validator
@@ -72,6 +106,28 @@ type Run struct {
 	postSetup          bool
 	postMainSetupDone  bool
 	named              bool
+	interactiveAbort   bool // the user chose to finish or kill the test; see promptFailure
+	interactiveKill    bool
+	result             runRecord
+	monitorResults     []monitorResult
+
+	// AlwaysDisruptors requested on the environment; see getDisruptors
+	alwaysDisruptors       []Disruptor
+	alwaysDisruptorsLoaded bool
+
+	// The step that started last, kept on the root for tagging the
+	// monitor samples; see setCurrentStep
+	currentStep   *Step
+	currentStepId string
+
+	// Set for the runners that own an artifact directory; see
+	// openArtifacts
+	artifactDir string
+	artifactLog *os.File
+
+	// Kept on the root; see runFailureHooks
+	failureHooks     []FailureHook
+	reportedFailures []error
 }
 
 // Return the full ID of the Runner, which includes the ID of its parent
@@ -101,22 +157,32 @@ func (r *Run) GetId() string {
 		kindLetter = "TD"
 	case MonitorRunner:
 		kindLetter = "M"
+	case ParallelRunner:
+		kindLetter = "P"
 	default:
 		panic("unhandled kind of Runner")
 	}
 	localId := fmt.Sprintf("%v%v", kindLetter, r.sequence)
-	if r.parent == nil || r.kind == SubTestRunner {
+	// Subtests of parallel branches keep the branch on their ID, so the
+	// concurrent ones can be told apart
+	if r.parent == nil || (r.kind == SubTestRunner && r.parent.kind != ParallelRunner) {
 		return fmt.Sprintf("%v", localId)
 	}
 	return fmt.Sprintf("%v.%v", r.parent.GetId(), localId)
 }
 
+// Protects the runner tree (children, sequences and the lists kept on the
+// root and named runners) from concurrent changes by Parallel substeps
+var runnerLock sync.Mutex
+
 // TODO: make just Child(), which reuses the runner's own T
 func (r *Run) ChildWithT(t *testing.T, kind RunnerType) *Run {
 	// TODO Should we allow this, or panic?
 	if r == nil {
 		return nil
 	}
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
 	root := r.root
 	if root == nil {
 		root = r
@@ -131,6 +197,10 @@ func (r *Run) ChildWithT(t *testing.T, kind RunnerType) *Run {
 		root:      root,
 		kind:      kind,
 	}
+	if kind == TearDownRunner && child.ctx != nil {
+		child.ctx = teardownContext(child.ctx)
+		child.cancelCtx = nil
+	}
 	r.nextChildSequence += 1
 	r.children = append(r.children, &child)
 
@@ -139,14 +209,16 @@ func (r *Run) ChildWithT(t *testing.T, kind RunnerType) *Run {
 
 func (r *Run) ReportChildren(indent int) {
 	for _, c := range r.children {
-		log.Printf("%v- %v %+v", strings.Repeat(" ", indent), c.GetId(), *c)
+		r.logf(LevelDebug, "%v- %v %+v", strings.Repeat(" ", indent), c.GetId(), *c)
 		c.ReportChildren(indent + 1)
 	}
 }
 
 // GetContext will always return a context.  If not defined on the
-// current level, check the parent.  If not on the parent, create a
-// new Background context.
+// current level, check the parent.  The root Run creates its context
+// on the first call, with a deadline before the test's own (see
+// newRootContext).  Teardown runners get a context without that
+// deadline.
 //
 // When contexts are created on this method, they get scheduled for
 // cancellation on T.Cleanup()
@@ -159,11 +231,23 @@ func (r *Run) GetContext() context.Context {
 	if r.ctx != nil {
 		return r.ctx
 	}
-	if ctx := r.parent.GetContext(); ctx != nil {
+	if r.parent != nil {
+		ctx := r.parent.GetContext()
+		if r.kind == TearDownRunner {
+			ctx = teardownContext(ctx)
+		}
 		return ctx
 	}
-	r.ctx, r.cancelCtx = context.WithCancel(context.Background())
-	r.savedT.Cleanup(r.cancelCtx)
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	if r.ctx == nil {
+		r.ctx, r.cancelCtx = r.newRootContext()
+		if t := r.savedT; t != nil {
+			t.Cleanup(r.cancelCtx)
+		} else if r.T != nil {
+			r.T.Cleanup(r.cancelCtx)
+		}
+	}
 	return r.ctx
 }
 
@@ -187,25 +271,32 @@ func (r *Run) CancelContext() {
 }
 
 func (r *Run) addMonitor(step *Monitor) {
-
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
 	r.monitors = append(r.monitors, step)
 }
 
 // Adds the list of validators v to the root runner's list of
 // final validators.
 //
-// Attention! items in v that are pointers to structs will be
-// copied as copies.  As such, changes to the original items
-// (such as those done by disruptors) will reflect on the
-// finalValidators list.
+// The list receives snapshots of the items in v (see snapshotValidators),
+// so later changes to the original items (such as those done by
+// disruptors) do not reflect on the finalValidators list.
 func (r *Run) addFinalValidators(v []Validator) {
+	snapshot := snapshotValidators(v)
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
 	root := r.getRoot()
-	root.finalValidators = append(root.finalValidators, v...)
+	root.finalValidators = append(root.finalValidators, snapshot...)
 }
 
+// Like addFinalValidators, for the named test the runner is part of
 func (r *Run) addSubFinalValidators(v []Validator) {
+	snapshot := snapshotValidators(v)
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
 	namedTest := r.getNamed()
-	namedTest.subFinalValidators = append(namedTest.subFinalValidators, v...)
+	namedTest.subFinalValidators = append(namedTest.subFinalValidators, snapshot...)
 }
 
 func (r *Run) getNamed() *Run {
@@ -232,8 +323,65 @@ func (r *Run) getRoot() *Run {
 	}
 }
 
+// Returns how deep the runner is on the tree (the number of runners above
+// it), for indentation
+func (r *Run) depth() int {
+	var depth int
+	for ; r.parent != nil; r = r.parent {
+		depth++
+	}
+	return depth
+}
+
+// Returns the disruptors for the test: those accepted by AllowDisruptors,
+// the requested AlwaysDisruptors and the RequiredDisruptors.  Disruptors
+// configured with a scope are returned unwrapped; use getScopedDisruptors
+// where the scope matters
 func (r *Run) getDisruptors() []Disruptor {
-	return append(r.getRoot().disruptor, r.getRoot().RequiredDisruptors...)
+	ret := []Disruptor{}
+	for _, d := range r.getScopedDisruptors() {
+		ret = append(ret, d.Disruptor)
+	}
+	return ret
+}
+
+// Like getDisruptors, but with their scopes (which are nil for
+// disruptors that apply everywhere)
+func (r *Run) getScopedDisruptors() []scopedDisruptor {
+	root := r.getRoot()
+	root.loadAlwaysDisruptors()
+	all := []Disruptor{}
+	all = append(all, root.disruptor...)
+	all = append(all, root.alwaysDisruptors...)
+	all = append(all, root.RequiredDisruptors...)
+	ret := []scopedDisruptor{}
+	for _, d := range all {
+		if scoped, ok := d.(scopedDisruptor); ok {
+			ret = append(ret, scoped)
+		} else {
+			ret = append(ret, scopedDisruptor{Disruptor: d})
+		}
+	}
+	return ret
+}
+
+// Instantiates the registered AlwaysDisruptors requested on ENV_DISRUPTOR.
+// It only does any work on its first call for a root runner
+func (r *Run) loadAlwaysDisruptors() {
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	if r.alwaysDisruptorsLoaded {
+		return
+	}
+	r.alwaysDisruptorsLoaded = true
+	for _, req := range requestedDisruptors() {
+		if !req.always() {
+			continue
+		}
+		disruptor := req.configure(newRegisteredDisruptor(req.name))
+		r.logf(LevelInfo, "DISRUPTOR: %v (always)", req.name)
+		r.alwaysDisruptors = append(r.alwaysDisruptors, disruptor)
+	}
 }
 
 // Run steps that are still part of the subtest, but must be run at its very end,
@@ -246,29 +394,29 @@ func (r *Run) subFinalize() {
 	if len(r.subFinalValidators) > 0 {
 		for _, d := range r.getDisruptors() {
 			if d, ok := d.(FinalizerHook); ok {
-				log.Printf("[R] Running pre-subfinalizer hook")
+				r.logf(LevelDebug, "[R] Running pre-subfinalizer hook")
 				var err error
 				r.T.Run("pre-subfinalizer-hook", func(t *testing.T) {
-					err = d.PreFinalizerHook(r.ChildWithT(t, HookRunner))
+					err = r.ChildWithT(t, HookRunner).runHook("pre-subfinalizer-hook", d.PreFinalizerHook)
 					if err != nil {
-						log.Printf("[R] %v test marked as failed on pre-subfinalizer hook: %v", t.Name(), err)
+						r.logf(LevelError, "[R] %v test marked as failed on pre-subfinalizer hook: %v", t.Name(), err)
 						t.Errorf("pre-subfinalizer hook failed: %v", err)
 					}
 				})
 			}
 		}
-		log.Printf("[R] Running sub test finalizers")
+		r.logf(LevelDebug, "[R] Running sub test finalizers")
 
 		// TODO Simplify: instead of t.Run() + phase, use just one named phase.
 		r.T.Run("subfinal-validator-re-run", func(t *testing.T) {
-			log.Printf("[R] Running sub test final validators")
+			r.logf(LevelDebug, "[R] Running sub test final validators")
 			subPhase := Phase{
 				Runner: r.ChildWithT(t, ValidatorRunner),
 				MainSteps: []Step{
 					{
 						Validators: r.subFinalValidators,
 						ValidatorRetry: RetryOptions{
-							Allow: GetInt(ENV_FINAL_RETRY, 1),
+							Allow: settingFinalRetry.Int(),
 						},
 					},
 				},
@@ -281,12 +429,12 @@ func (r *Run) subFinalize() {
 		// post-sub-finalizer-hook for any disruptors
 		for _, d := range r.getDisruptors() {
 			if d, ok := d.(FinalizerHook); ok {
-				log.Printf("[R] Running post-subfinalizer hook")
+				r.logf(LevelDebug, "[R] Running post-subfinalizer hook")
 				var err error
 				r.T.Run("post-subfinalizer-hook", func(t *testing.T) {
-					err = d.PostSubFinalizerHook(r.ChildWithT(t, HookRunner))
+					err = r.ChildWithT(t, HookRunner).runHook("post-subfinalizer-hook", d.PostSubFinalizerHook)
 					if err != nil {
-						log.Printf("[R] %v test marked as failed on post-subfinalizer hook: %v", t.Name(), err)
+						r.logf(LevelError, "[R] %v test marked as failed on post-subfinalizer hook: %v", t.Name(), err)
 						t.Errorf("post-subfinalizer hook failed: %v", err)
 					}
 				})
@@ -300,18 +448,18 @@ func (r *Run) subFinalize() {
 func (r *Run) Finalize() {
 	for _, d := range r.getDisruptors() {
 		if d, ok := d.(FinalizerHook); ok {
-			log.Printf("[R] Running pre-finalizer hook")
+			r.logf(LevelDebug, "[R] Running pre-finalizer hook")
 			var err error
 			r.T.Run("pre-finalizer-hook", func(t *testing.T) {
-				err = d.PreFinalizerHook(r.ChildWithT(t, HookRunner))
+				err = r.ChildWithT(t, HookRunner).runHook("pre-finalizer-hook", d.PreFinalizerHook)
 				if err != nil {
-					log.Printf("[R] %v test marked as failed on pre-finalizer hook: %v", t.Name(), err)
+					r.logf(LevelError, "[R] %v test marked as failed on pre-finalizer hook: %v", t.Name(), err)
 					t.Errorf("pre-finalizer hook failed: %v", err)
 				}
 			})
 		}
 	}
-	log.Printf("[R] Running finalizers")
+	r.logf(LevelDebug, "[R] Running finalizers")
 
 	if len(r.finalValidators) > 0 {
 		finalValidatorPhase := Phase{
@@ -322,7 +470,7 @@ func (r *Run) Finalize() {
 				{
 					Validators: r.finalValidators,
 					ValidatorRetry: RetryOptions{
-						Allow: GetInt(ENV_FINAL_RETRY, 1),
+						Allow: settingFinalRetry.Int(),
 					},
 				},
 			},
@@ -335,8 +483,20 @@ func (r *Run) Finalize() {
 	// r.ReportChildren(0)
 }
 
+// Runs a disruptor hook with this runner, emitting its start and end events
+func (r *Run) runHook(name string, hook func(*Run) error) error {
+	r.emit(Event{Kind: EventHookStart, Name: name})
+	start := time.Now()
+	err := hook(r)
+	r.emit(Event{Kind: EventHookEnd, Name: name, Duration: time.Since(start), Err: err})
+	return err
+}
+
 // This will cause all active monitors to report their status on the logs.
 //
+// If ENV_REPORT_DIR is set, the JUnit and HTML reports for the test are
+// also written to that directory (see Run.WriteReports)
+//
 // It should generally be run as defer r.Report(), right after the Run creation
 func (r *Run) Report() {
 
@@ -346,12 +506,21 @@ func (r *Run) Report() {
 		if err != nil {
 			failed = true
 		}
+		r.monitorResults = append(r.monitorResults, monitorResult{name: frameName(*m), err: err})
 	}
 	if failed {
-		log.Printf("[R] test marked as failed due to monitor failure")
+		r.logf(LevelError, "[R] test marked as failed due to monitor failure")
 		r.savedT.Errorf("At least one monitor failed")
 	}
 
+	r.logf(LevelInfo, "[R] steps: %v", r.ReportTree().StepCounts())
+
+	if dir := settingReportDir.Value(); dir != "" {
+		if err := r.WriteReports(dir); err != nil {
+			r.logf(LevelWarn, "[R] failed to write reports: %v", err)
+		}
+	}
+
 }
 
 // List the disruptors that a test accepts, and initialize a disruptor if
@@ -359,7 +528,9 @@ func (r *Run) Report() {
 //
 // If any of the values on the environment variable does not match a value on
 // the list, the test will be skipped in this run (ie, a disruptor test was
-// requested, but the test does not allow for it).
+// requested, but the test does not allow for it).  Registered AlwaysDisruptors
+// (see RegisterDisruptor) do not need to be on the list; they apply to any
+// test.
 //
 // If the environment variable is empty, this is a no-op.
 //
@@ -367,9 +538,9 @@ func (r *Run) Report() {
 // them on the list as a reference to the struct.  Otherwise, the pointer reference
 // methods will not be part of the method set, and some interfaces may not match
 func (r *Run) AllowDisruptors(list []Disruptor) {
-	kind := os.Getenv("SKUPPER_TEST_DISRUPTOR")
+	requests := requestedDisruptors()
 
-	if kind == "" {
+	if len(requests) == 0 {
 		// No disruptor requested
 		return
 	}
@@ -378,42 +549,25 @@ func (r *Run) AllowDisruptors(list []Disruptor) {
 		r.T.Fatalf("attempt to re-define the disruptor. Was %s", r.getRoot().disruptor)
 	}
 
-	disruptor_list := strings.Split(kind, ";")
-
 outer:
-	for _, d := range disruptor_list {
-		name, conf, _ := strings.Cut(d, ":")
+	for _, req := range requests {
+		if req.always() {
+			// Loaded for any test by getDisruptors
+			continue
+		}
 		for _, allowed := range list {
-			if name == allowed.DisruptorEnvValue() {
-				log.Printf("DISRUPTOR: %v", name)
-				if conf != "" {
-					if allowed, ok := allowed.(DisruptorConfigurer); ok {
-						err := allowed.Configure(conf)
-						if err != nil {
-							panic(fmt.Sprintf("Failed configuration for %q: %v", name, err))
-						}
-					} else {
-						panic(fmt.Sprintf("Disruptor %q does not accept configuration", name))
-					}
-					log.Printf("Configured disruptor: %+v", allowed)
+			if req.name == allowed.DisruptorEnvValue() {
+				r.logf(LevelInfo, "DISRUPTOR: %v", req.name)
+				if req.conf != "" {
+					allowed = req.configure(allowed)
+					r.logf(LevelDebug, "Configured disruptor: %+v", allowed)
 				}
 				r.getRoot().disruptor = append(r.getRoot().disruptor, allowed)
 				continue outer
 			}
 		}
-		r.T.Skipf("This test does not support the disruptor %q", d)
-	}
-
-	/* TODO This should replace the loop above; check for any AlwaysDisruptors
-	 *      However, how to get the Disruptor class for the given env variable
-	 *      value?  This will need some type of plugin 'subscription'
-	for _, d := range r.getRoot().disruptor {
-		if _, ok := d.(AlwaysDisruptor); ok {
-			log.Printf("DISRUPTOR: %v", d)
-			r.getRoot
-		}
+		r.T.Skipf("This test does not support the disruptor %q", req.name)
 	}
-	*/
 
 }
 
@@ -432,6 +586,10 @@ type Phase struct {
 	teardowns []Executor
 	Runner    *Run
 
+	// Like Step.Timeout, for the Setup and MainSteps of the phase.  The
+	// Teardown steps are not bound by it
+	Timeout time.Duration
+
 	savedRunner *Run
 	previousRun bool
 	connected   bool
@@ -466,6 +624,12 @@ func processStep(t *testing.T, step Step, Log FrameLogger, p *Phase, kind Runner
 		return nil
 	}
 
+	inTeardown := kind == TearDownRunner || p.GetRunner().inTeardown()
+	if !inTeardown && p.GetRunner().interactiveAborted() {
+		Log.Printf("[R] %v step not run: the test was finished interactively (%s)", id, step.Doc)
+		return fmt.Errorf("test finished interactively")
+	}
+
 	if step.Name != "" {
 		// For a named test, run or fail, we work the same.  It's up to t to
 		// mark it as failed
@@ -474,9 +638,21 @@ func processStep(t *testing.T, step Step, Log FrameLogger, p *Phase, kind Runner
 			//log.Printf("[R] %v current test: %q", id, t.Name())
 			Log.Printf("[R] %v Doc: %v", id, step.Doc)
 			processErr := processStep_(t, step, SubTestRunner, Log, p, true)
+			if skip, ok := AsSkip(processErr); ok {
+				Log.Printf("[R] %v step %q skipped: %v", id, t.Name(), skip.Reason)
+				t.Skip(skip.Reason)
+			}
+			if processErr != nil && !inTeardown {
+				action := promptFailure(t, id, processErr)
+				p.GetRunner().setInteractiveAction(action)
+				if action == actionContinue {
+					Log.Printf("[R] test %v - %q failure ignored interactively: %v", id, t.Name(), processErr)
+					processErr = nil
+				}
+			}
 			if processErr != nil {
 				// This makes it easier to find the failures in log files
-				log.Printf("[R] test %v - %q failed", id, t.Name())
+				p.GetRunner().logf(LevelError, "[R] test %v - %q failed", id, t.Name())
 				Log.Printf("[R] test %v - %q failed", id, t.Name())
 				// For named tests, we do not return the error up; we
 				// just mark it as a failed test
@@ -494,6 +670,10 @@ func processStep(t *testing.T, step Step, Log FrameLogger, p *Phase, kind Runner
 		*/
 		err = processStep_(t, step, kind, Log, p, false)
 		//Log.Printf("[R] Step %q result %+v", id, err)
+		if skip, ok := AsSkip(err); ok {
+			Log.Printf("[R] %v step skipped (%s): %v", id, step.Doc, skip.Reason)
+			err = nil
+		}
 	}
 	return err
 
@@ -501,7 +681,7 @@ func processStep(t *testing.T, step Step, Log FrameLogger, p *Phase, kind Runner
 
 // Does the heavy lifting of executing a single step from a phase; execute each of its
 // parts: setup, modify, substeps, validations, etc
-func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *Phase, named bool) error {
+func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *Phase, named bool) (err error) {
 
 	var testFailed bool
 
@@ -512,20 +692,52 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 	stepRunner := p.DefaultRunDealer.GetRunner().ChildWithT(t, kind)
 	stepRunner.named = named
 	if named {
+		stepRunner.openArtifacts()
 		defer stepRunner.subFinalize()
+		// Named steps do not return their failures to the phase, so their
+		// hooks run here, on the step's own runner
+		defer func() {
+			stepRunner.runFailureHooks(err)
+		}()
 	}
 	id := stepRunner.GetId()
+	Log = recordingLogger{FrameLogger: Log, runner: stepRunner}
 	Log.Printf("[R] %v doc %q", id, step.Doc)
 
-	// Before we run the disruptors, we need to save the final and subfinal validators;
-	// otherwise, we'd save the copies already with any disruptor changes
-	//
-	// TODO: this is incorrect.  Today, for pointer structs, we're copying the poitners,
-	// so the disruptors need to be able to 'undo' its changes if required.  For fixing this,
-	// either Validator will need to implement Clone(), or addFinalValidators  and
-	// addSubFinalValidator will need to copy the list through reflection.
-	//
-	//
+	stepRunner.emit(Event{Kind: EventStepStart, Name: step.Name, Doc: step.Doc, Frame: frameName(step.Modify)})
+	stepStart := time.Now()
+	defer stepRunner.setCurrentStep(step)()
+	defer func() {
+		stepRunner.emit(Event{
+			Kind:     EventStepEnd,
+			Name:     step.Name,
+			Doc:      step.Doc,
+			Frame:    frameName(step.Modify),
+			Duration: time.Since(stepStart),
+			Err:      err,
+		})
+	}()
+
+	if step.Timeout > 0 {
+		return stepRunner.runWithTimeout(step.Timeout, func() error {
+			return runStep(t, step, Log, p, stepRunner, testFailed)
+		})
+	}
+	return runStep(t, step, Log, p, stepRunner, testFailed)
+}
+
+// Runs the parts of the step, on its runner, after processStep_ set it up
+func runStep(t *testing.T, step Step, Log FrameLogger, p *Phase, stepRunner *Run, testFailed bool) error {
+	id := stepRunner.GetId()
+	if err := stepRunner.contextDone(); err != nil {
+		Log.Printf("[R] %v not run: %v", id, err)
+		return err
+	}
+
+	// Before we run the disruptors (and set the context on the frames), we need to
+	// save the final and subfinal validators; otherwise, we'd save the copies
+	// already with any disruptor changes.  The lists get snapshots of the
+	// validators, so the changes done later on the originals do not affect them
 	validatorList := step.Validators
 	if step.Validator != nil {
 		validatorList = append([]Validator{step.Validator}, validatorList...)
@@ -537,14 +749,22 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 		p.savedRunner.addSubFinalValidators(validatorList)
 	}
 
-	for _, disruptor := range p.GetRunner().getDisruptors() {
-		if disruptor != nil {
-			if disruptor, ok := disruptor.(Inspector); ok {
-				disruptor.Inspect(&step, p)
-			}
+	for _, disruptor := range p.GetRunner().getScopedDisruptors() {
+		if disruptor.Disruptor != nil {
+			disruptor.inspect(&step, p, id)
 		}
 	}
 
+	// Frames without a context of their own get the runner's, so they
+	// are bound by the test deadline.  The list is copied, so frames given
+	// as values are not replaced on the caller's step
+	step.Validators = append([]Validator{}, step.Validators...)
+	if err := step.IterFrames(injectContext(stepRunner.GetContext())); err != nil {
+		return fmt.Errorf("failed to set the context on the frames: %w", err)
+	}
+	_ = step.IterFrames(linkLog(stepRunner))
+	validatorList = step.GetValidators()
+
 	if step.Modify != nil {
 		var modifyRunner *Run
 		if mod, ok := step.Modify.(RunDealer); ok {
@@ -556,6 +776,7 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 		id := modifyRunner.GetId()
 		Log.Printf("[R] %v Modifier %T", id, step.Modify)
 		var err error
+		modifyRunner.emit(Event{Kind: EventModifyStart, Doc: step.Doc, Frame: frameName(step.Modify)})
 		start := time.Now()
 		if phase, ok := step.Modify.(Phase); ok {
 			err = phase.runP(modifyRunner)
@@ -564,6 +785,12 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 			err = step.Modify.Execute()
 		}
 		duration := time.Now().Sub(start)
+		modifyRunner.emit(Event{Kind: EventModifyEnd, Doc: step.Doc, Frame: frameName(step.Modify), Duration: duration, Err: err})
+		if skip, ok := AsSkip(err); ok {
+			// The rest of the step does not apply either
+			Log.Printf("[R] %v modify-skipped %T (%v): %v", id, step.Modify, duration, skip.Reason)
+			return skip
+		}
 		if err != nil {
 			Log.Printf("[R] %v modify-not-ok %T (%v): %v", id, step.Modify, duration, err)
 			return fmt.Errorf("modify step failed: %w", err)
@@ -573,32 +800,43 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 	}
 	if t != nil && t.Failed() != testFailed {
 		testFailed = true
-		log.Printf("[R] %v Test %q marked as failed after modify step", id, t.Name())
+		stepRunner.logf(LevelError, "[R] %v Test %q marked as failed after modify step", id, t.Name())
 	}
 
-	subStepList := step.Substeps
-	if step.Substep != nil {
-		subStepList = append([]*Step{step.Substep.GetStep()}, step.Substeps...)
+	subStepList := step.GetSubsteps()
+	if step.Parallel && len(subStepList) > 0 {
+		if err := runParallel(t, step, subStepList, Log, p, stepRunner); err != nil {
+			return fmt.Errorf("parallel substeps failed: %w", err)
+		}
+		if t != nil && t.Failed() != testFailed {
+			testFailed = true
+			stepRunner.logf(LevelError, "[R] %v Test %q marked as failed after parallel substeps", id, t.Name())
+		}
+		subStepList = nil
 	}
 	for _, subStep := range subStepList {
+		var attempt int
 		_, err := Retry{
 			Fn: func() error {
-				return processStep(t, *subStep, Log, p, SubTestRunner)
+				if err := stepRunner.contextDone(); err != nil {
+					return Permanent(err)
+				}
+				attempt++
+				err := processStep(t, *subStep, Log, p, SubTestRunner)
+				stepRunner.emit(Event{Kind: EventRetryAttempt, Name: subStep.Name, Doc: subStep.Doc, Attempt: attempt, Err: err})
+				return err
 			},
-			Options: step.SubstepRetry,
+			Options: stepRunner.retryOptions(step.SubstepRetry),
 		}.Run()
 		if err != nil {
 			return fmt.Errorf("substep failed: %w", err)
 		}
 		if t != nil && t.Failed() != testFailed {
 			testFailed = true
-			log.Printf("[R] %v Test %q marked as failed after substep", id, t.Name())
+			stepRunner.logf(LevelError, "[R] %v Test %q marked as failed after substep", id, t.Name())
 		}
 	}
 
-	// TODO: For every RetryOptions received, we should make a copy and use the copy.
-	// The copy should be checked for a Context.  If none, the main Runner context
-	// should be set there.
 	if len(validatorList) > 0 {
 		start := time.Now()
 		for _, v := range validatorList {
@@ -610,18 +848,28 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 		// This is a generic Runner, if the validtor is not a RunDealer
 		// TODO remove this once all actions are RunDealers
 		validatorRunner := stepRunner.ChildWithT(t, ValidatorRunner)
+		var attempt int
+		// Set when all validators were skipped on the last attempt
+		var skip *ErrSkip
 		fn := func() error {
+			attempt++
 			someFailure := false
 			someSuccess := false
+			skipped := 0
+			skip = nil
 			var lastErr error
 			var lastErrValidator Validator
 			for i, v := range validatorList {
-				var id string
+				if err := stepRunner.contextDone(); err != nil {
+					return Permanent(err)
+				}
+				var vRunner *Run
 				if v, ok := v.(RunDealer); ok {
-					id = v.GetRunner().GetId()
+					vRunner = v.GetRunner()
 				} else {
-					id = validatorRunner.GetId()
+					vRunner = validatorRunner
 				}
+				id := vRunner.GetId()
 
 				// TODO remove this once the SetRunner thing is fixed above
 				//      (see the TODO TODO TODO line)
@@ -629,8 +877,24 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 
 				Log.Printf("[R] %v.v%d Validator %T", id, i, v)
 				// TODO: create and set individual runners for each validator?
+				vRunner.emit(Event{Kind: EventValidatorStart, Doc: step.Doc, Frame: frameName(v), Attempt: attempt})
+				vStart := time.Now()
 				err := v.Validate()
-				if err == nil {
+				vRunner.emit(Event{
+					Kind:     EventValidatorEnd,
+					Doc:      step.Doc,
+					Frame:    frameName(v),
+					Attempt:  attempt,
+					Duration: time.Since(vStart),
+					Err:      err,
+				})
+				if s, ok := AsSkip(err); ok {
+					Log.Printf("[R] %v.v%d Validator %T skipped: %v", id, i, v, s.Reason)
+					skipped++
+					if skipped == len(validatorList) {
+						skip = s
+					}
+				} else if err == nil {
 					someSuccess = true
 				} else {
 					someFailure = true
@@ -654,10 +918,21 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 		}
 
 		_, err := Retry{
-			Fn:      fn,
-			Options: step.ValidatorRetry,
+			Fn: func() error {
+				if err := stepRunner.contextDone(); err != nil {
+					return Permanent(err)
+				}
+				err := fn()
+				stepRunner.emit(Event{Kind: EventRetryAttempt, Doc: step.Doc, Attempt: attempt, Err: err})
+				return err
+			},
+			Options: stepRunner.retryOptions(step.ValidatorRetry),
 		}.Run()
 		elapsed := time.Now().Sub(start)
+		if err == nil && skip != nil {
+			Log.Printf("[R] %v validation-skipped (%v)", id, elapsed)
+			return skip
+		}
 		if err == nil {
 			Log.Printf("[R] %v validation-ok (%v)", id, elapsed)
 		} else {
@@ -665,18 +940,105 @@ func processStep_(t *testing.T, step Step, kind RunnerType, Log FrameLogger, p *
 		}
 		if t != nil && t.Failed() != testFailed {
 			testFailed = true
-			log.Printf("[R] %v Test %q marked as failed after validate step", id, t.Name())
+			stepRunner.logf(LevelError, "[R] %v Test %q marked as failed after validate step", id, t.Name())
 		}
-		return validationResultHook(p.GetRunner(), step, err)
+		return validationResultHook(p.GetRunner(), step, id, err)
 	}
 	return nil
 }
 
+// Whether the runner runs on the goroutine of a Parallel branch, where
+// t.FailNow and its kin must not be called: they belong to the test's own
+// goroutine.  Named substeps get their own goroutine from t.Run, so they
+// end the search
+func (r *Run) onParallelBranch() bool {
+	for run := r; run != nil; run = run.parent {
+		switch run.kind {
+		case ParallelRunner:
+			return true
+		case SubTestRunner:
+			return false
+		}
+	}
+	return false
+}
+
+// Runs the substeps concurrently, each on its own ParallelRunner under
+// stepRunner, and returns their errors joined.  See Step.Parallel.
+//
+// Failures that would stop the test (such as a nested Phase's setup
+// failure) are returned as errors by the branches instead
+func runParallel(t *testing.T, step Step, subSteps []*Step, Log FrameLogger, p *Phase, stepRunner *Run) error {
+	// The branch runners are created upfront, so their IDs follow the
+	// declaration order, and not the scheduling
+	branches := make([]*Run, len(subSteps))
+	for i := range subSteps {
+		branches[i] = stepRunner.ChildWithT(t, ParallelRunner)
+	}
+
+	limit := step.ParallelLimit
+	if limit <= 0 {
+		limit = len(subSteps)
+	}
+	sem := make(chan struct{}, limit)
+	errs := make([]error, len(subSteps))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+
+	for i, subStep := range subSteps {
+		sem <- struct{}{}
+		if step.ParallelFailFast && failed.Load() {
+			<-sem
+			Log.Printf("[R] %v and later parallel substeps not started, as a previous one failed", branches[i].GetId())
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			// A frame that calls t.FailNow (or runtime.Goexit) from the
+			// branch ends its goroutine before the error is set
+			finished := false
+			defer func() {
+				if !finished {
+					Log.Printf("[R] %v parallel substep stopped its goroutine", branches[i].GetId())
+					errs[i] = fmt.Errorf("%v: parallel substep stopped its goroutine (t.FailNow or runtime.Goexit called on it)", branches[i].GetId())
+					failed.Store(true)
+				}
+			}()
+			// Each branch gets its own copy of the phase, so that the
+			// runners created by processStep hang from the branch runner
+			branchPhase := *p
+			branchPhase.DefaultRunDealer.Runner = branches[i]
+			var attempt int
+			_, err := Retry{
+				Fn: func() error {
+					attempt++
+					err := processStep(t, *subStep, Log, &branchPhase, StepRunner)
+					branches[i].emit(Event{Kind: EventRetryAttempt, Name: subStep.Name, Doc: subStep.Doc, Attempt: attempt, Err: err})
+					return err
+				},
+				Options: branches[i].retryOptions(step.SubstepRetry),
+			}.Run()
+			if err != nil {
+				Log.Printf("[R] %v parallel substep failed: %v", branches[i].GetId(), err)
+				errs[i] = fmt.Errorf("%v: %w", branches[i].GetId(), err)
+				failed.Store(true)
+			}
+			finished = true
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
 // Hook for validation result; the handler may change the err (wrap,
 // turn into nil or even change it to some other error altogether).
-func validationResultHook(runner *Run, step Step, err error) error {
-	for _, d := range runner.getDisruptors() {
-		if d, ok := d.(ValidationResultHook); ok {
+//
+// The id is that of the step's runner, for the disruptor scopes
+func validationResultHook(runner *Run, step Step, id string, err error) error {
+	for _, scoped := range runner.getScopedDisruptors() {
+		if d, ok := scoped.Disruptor.(ValidationResultHook); ok && scoped.validationInScope(step, id) {
 			err = d.ValidationResultHook(runner, step, err)
 		}
 	}
@@ -722,6 +1084,10 @@ func (p *Phase) Run() error {
 
 // Run phase; it creates a child runner for the given one
 func (p *Phase) runP(runner *Run) error {
+	if planMode() {
+		return p.plan(runner)
+	}
+
 	var err error
 
 	var id string
@@ -731,13 +1097,14 @@ func (p *Phase) runP(runner *Run) error {
 		ok := p.GetRunner().T.Run(p.Name, func(t *testing.T) {
 			p.DefaultRunDealer.Runner = runner.ChildWithT(t, PhaseRunner)
 			p.Runner.named = true
+			p.GetRunner().openArtifacts()
 			id = p.GetRunner().GetId()
-			log.Printf("[R] %v current test: %q", id, t.Name())
+			p.GetRunner().logf(LevelInfo, "[R] %v current test: %q", id, t.Name())
 			p.Log.Printf("[R] %v Phase doc: %v", id, p.Doc)
-			err = p.run()
+			err = p.runWithTimeout()
 			if err != nil {
 				p.Log.Printf("[R] %v phase failed: %v", id, err)
-				log.Printf("[R] %v Test %q marked as failed after phase: %v", id, p.GetRunner().T.Name(), err)
+				p.GetRunner().logf(LevelError, "[R] %v Test %q marked as failed after phase: %v", id, p.GetRunner().T.Name(), err)
 				t.Errorf("Phase failed: %v", err)
 			}
 			p.GetRunner().subFinalize()
@@ -754,7 +1121,7 @@ func (p *Phase) runP(runner *Run) error {
 		p.SetRunner(runner, PhaseRunner)
 		id = p.GetRunner().GetId()
 		p.Log.Printf("[R] %v Phase doc: %v", id, p.Doc)
-		err = p.run()
+		err = p.runWithTimeout()
 	}
 
 	if err != nil {
@@ -765,6 +1132,29 @@ func (p *Phase) runP(runner *Run) error {
 	return err
 }
 
+// Runs the phase within its Timeout, if any
+func (p *Phase) runWithTimeout() error {
+	if p.Timeout <= 0 {
+		return p.runWithEvents()
+	}
+	if p.GetRunner() == nil {
+		// As on run(), for phases used without a runner
+		p.Runner = &Run{}
+		p.DefaultRunDealer.Runner = p.Runner
+	}
+	return p.GetRunner().runWithTimeout(p.Timeout, p.runWithEvents)
+}
+
+// Wraps p.run() with the phase start and end events
+func (p *Phase) runWithEvents() error {
+	runner := p.GetRunner()
+	runner.emit(Event{Kind: EventPhaseStart, Name: p.Name, Doc: p.Doc})
+	start := time.Now()
+	err := p.run()
+	runner.emit(Event{Kind: EventPhaseEnd, Name: p.Name, Doc: p.Doc, Duration: time.Since(start), Err: err})
+	return err
+}
+
 func (p *Phase) addMonitor(monitor *Monitor) {
 	p.GetRunner().getRoot().addMonitor(monitor)
 
@@ -807,6 +1197,15 @@ func (p *Phase) run() error {
 		p.previousRun = true
 	}
 
+	if err := validateSettings(); err != nil {
+		return err
+	}
+
+	// The root context is created before the teardown is scheduled, so that
+	// its cancellation runs only after the teardown (T.Cleanup is LIFO)
+	runner.GetContext()
+	// Same for the artifacts, so the summary includes the teardown
+	runner.getRoot().openArtifacts()
 	if t != nil {
 		t.Cleanup(p.teardown)
 	}
@@ -817,19 +1216,30 @@ func (p *Phase) run() error {
 
 	if len(p.Setup) > 0 {
 		for _, step := range p.Setup {
-			if step.Modify != nil {
-				if downerStep, ok := step.Modify.(TearDowner); ok {
-					tdFunction := downerStep.Teardown()
-
-					if tdFunction != nil {
-						p.Log.Printf("[R] %v Installed auto-teardown for %T", idPrefix, step.Modify)
-						p.teardowns = append(p.teardowns, downerStep.Teardown())
-					}
+			if err := runner.contextDone(); err != nil {
+				// The caller reports it; if the phase timed out, it was
+				// already reported, and the test may be over
+				runner.logf(LevelWarn, "[R] %v setup stopped: %v", idPrefix, err)
+				return err
+			}
+			p.addAutoTeardown(idPrefix, step.Modify)
+			if step.Parallel {
+				// A group of parallel steps should behave as if they had
+				// been listed individually
+				for _, subStep := range step.GetSubsteps() {
+					p.addAutoTeardown(idPrefix, subStep.Modify)
 				}
 			}
 			if err := processStep(t, step, &p.Log, p, SetupRunner); err != nil {
+				p.GetRunner().runFailureHooks(err)
+				if t != nil && runner.onParallelBranch() {
+					// t.Fatalf cannot be called from the branch goroutine;
+					// the error fails the branch instead
+					runner.logf(LevelError, "[R] %v setup failed on a parallel branch: %v", idPrefix, err)
+					return fmt.Errorf("setup failed: %w", err)
+				}
 				if t != nil {
-					log.Printf("[R] %v test marked as failed on setup: %v", t.Name(), err)
+					runner.logf(LevelError, "[R] %v test marked as failed on setup: %v", t.Name(), err)
 					p.GetRunner().subFinalize()
 					t.Fatalf("setup failed: %v", err)
 				}
@@ -853,9 +1263,12 @@ func (p *Phase) run() error {
 
 					runner.getRoot().postMainSetupDone = true
 
-					log.Printf("[R] Running post-main-setup hook")
-					err := d.PostMainSetupHook(runner.ChildWithT(t, HookRunner))
+					runner.logf(LevelDebug, "[R] Running post-main-setup hook")
+					err := runner.ChildWithT(t, HookRunner).runHook("post-main-setup-hook", d.PostMainSetupHook)
 					if err != nil {
+						if runner.onParallelBranch() {
+							return fmt.Errorf("post-setup hook failed: %w", err)
+						}
 						runner.T.Fatalf("post-setup hook failed: %v", err)
 					}
 				}
@@ -864,19 +1277,18 @@ func (p *Phase) run() error {
 		p.GetRunner().postSetup = true
 		// log.Printf("Starting main steps")
 		for _, step := range p.MainSteps {
+			if err := runner.contextDone(); err != nil {
+				runner.logf(LevelWarn, "[R] %v main steps stopped: %v", idPrefix, err)
+				savedErr = err
+				break
+			}
 			if err := processStep(t, step, &p.Log, p, StepRunner); err != nil {
 				savedErr = err
 				if t != nil && step.Name != "" {
-					// TODO: Interact:
-					// - continue (ignore error)
-					// - hold (show time left for the test)
-					// - kill (run no teardown)
-					// - finish (run teardowns; go to next test if available)
-					log.Printf("[R] %v test failed: %v", idPrefix, err)
+					runner.logf(LevelError, "[R] %v test failed: %v", idPrefix, err)
 					t.Errorf("[R] %v test failed: %v", idPrefix, err)
 				}
-				// TODO this should be pluggable
-				//p.BaseRunner.DumpTestInfo(p.Name)
+				p.GetRunner().runFailureHooks(err)
 				break
 			}
 			if monitorStep, ok := step.Modify.(Monitor); ok {
@@ -895,10 +1307,38 @@ func (p *Phase) run() error {
 	return savedErr
 }
 
+// If the executor is a TearDowner, install its teardown on the phase
+func (p *Phase) addAutoTeardown(idPrefix string, modify Executor) {
+	if modify == nil {
+		return
+	}
+	if downerStep, ok := modify.(TearDowner); ok {
+		tdFunction := downerStep.Teardown()
+
+		if tdFunction != nil {
+			p.Log.Printf("[R] %v Installed auto-teardown for %T", idPrefix, modify)
+			p.teardowns = append(p.teardowns, downerStep.Teardown())
+		}
+	}
+}
+
 // TODO: thought for later.  Could a user control the order of individual teardowns (automatic
 // and explicit) by using different phases?
 func (p *Phase) teardown() {
 	t := p.GetRunner().T
+	if len(p.Teardown) == 0 && len(p.teardowns) == 0 {
+		return
+	}
+	if p.GetRunner().interactiveKilled() {
+		p.Log.Printf("[R] %v teardown skipped: the test was killed interactively", p.GetRunner().GetId())
+		return
+	}
+	var failed error
+	p.GetRunner().emit(Event{Kind: EventTeardownStart, Name: p.Name, Doc: p.Doc})
+	start := time.Now()
+	defer func() {
+		p.GetRunner().emit(Event{Kind: EventTeardownEnd, Name: p.Name, Doc: p.Doc, Duration: time.Since(start), Err: failed})
+	}()
 	// TODO: if both p.Teardown and p.teardowns were the same interface, this could be
 	// a single loop.  Or: leave the individual tear downs to t.Cleanup
 
@@ -907,10 +1347,11 @@ func (p *Phase) teardown() {
 		// This one runs in normal order, since the user listed them themselves
 		for i, step := range p.Teardown {
 			if err := processStep(t, step, &p.Log, p, TearDownRunner); err != nil {
+				failed = err
 				if t == nil {
 					p.Log.Printf("Tear down step %d failed: %v", i, err)
 				} else {
-					log.Printf("[R] %v test failed on teardown: %v", t.Name(), err)
+					p.GetRunner().logf(LevelError, "[R] %v test failed on teardown: %v", t.Name(), err)
 					t.Errorf("teardown failed: %v", err)
 				}
 				// We do not return here; we keep going doing whatever
@@ -922,7 +1363,11 @@ func (p *Phase) teardown() {
 	if len(p.teardowns) > 0 {
 		// TODO move this to t.Cleanup and make it depend on t != nil?
 		// This one runs in reverse order, since they were added by the setup steps
-		p.Log.Printf("Starting auto-teardown for %s", t.Name())
+		name := p.GetRunner().GetId()
+		if t != nil {
+			name = t.Name()
+		}
+		p.Log.Printf("Starting auto-teardown for %s", name)
 		for i := len(p.teardowns) - 1; i >= 0; i-- {
 			td := p.teardowns[i]
 			p.Log.Printf("[R] Teardown: %T", td)
@@ -930,10 +1375,11 @@ func (p *Phase) teardown() {
 				Modify: td,
 			}
 			if err := processStep(t, autoTearDownStep, &p.Log, p, TearDownRunner); err != nil {
+				failed = err
 				if t == nil {
 					p.Log.Printf("auto-teardown failed: %v", err)
 				} else {
-					log.Printf("[R] %v test failed on auto-teardown: %v", t.Name(), err)
+					p.GetRunner().logf(LevelError, "[R] %v test failed on auto-teardown: %v", t.Name(), err)
 					t.Errorf("auto-teardown failed: %v", err)
 				}
 				// We do not return here; we keep going doing whatever
@@ -962,17 +1408,16 @@ type DefaultRunDealer struct {
 	// For now it is public to break less things
 	Runner *Run
 
-	// TODO
-	// When implemented, should it really be here, or have another one
-	// for it?
-	// This needs to be public, so user can change it at will?
+	// If nil when the frame's step runs, it is set to the step runner's
+	// context, which carries the test deadline and any Step.Timeout or
+	// Phase.Timeout above it.  A context set by the user is kept
 	Ctx context.Context
 }
 
 func (d *DefaultRunDealer) SetRunner(parent *Run, kind RunnerType) {
 	if d == nil {
-		log.Printf("Nil DefaultRunDealer; parent %v, kind %v", parent, kind)
-		log.Printf("DefaultRunDealer should not be embedded as a reference type")
+		parent.logf(LevelWarn, "Nil DefaultRunDealer; parent %v, kind %v", parent, kind)
+		parent.logf(LevelWarn, "DefaultRunDealer should not be embedded as a reference type")
 	}
 	if parent == nil {
 		d.Runner = nil
@@ -1,10 +1,13 @@
 package frame2_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/hash-d/frame2/pkg/frames/f2general"
 	"io"
 	"log"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -465,3 +468,289 @@ func TestValidations(t *testing.T) {
 	assert.Assert(t, p.Run())
 
 }
+
+// Counts its executions and its tear downs; used by TestParallelSteps
+type parallelCounter struct {
+	running    *int32
+	maxRunning *int32
+	started    *int32
+	tornDown   *int32
+	err        error
+}
+
+func (c parallelCounter) Execute() error {
+	atomic.AddInt32(c.started, 1)
+	now := atomic.AddInt32(c.running, 1)
+	defer atomic.AddInt32(c.running, -1)
+	for {
+		max := atomic.LoadInt32(c.maxRunning)
+		if now <= max || atomic.CompareAndSwapInt32(c.maxRunning, max, now) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return c.err
+}
+
+func (c parallelCounter) Teardown() frame2.Executor {
+	return f2general.Function{
+		Fn: func() error {
+			atomic.AddInt32(c.tornDown, 1)
+			return nil
+		},
+	}
+}
+
+func TestParallelSteps(t *testing.T) {
+	var running, maxRunning, started, tornDown int32
+	reset := func() {
+		running, maxRunning, started, tornDown = 0, 0, 0, 0
+	}
+	substeps := func(errs ...error) []*frame2.Step {
+		ret := []*frame2.Step{}
+		for _, err := range errs {
+			ret = append(ret, &frame2.Step{
+				Modify: parallelCounter{&running, &maxRunning, &started, &tornDown, err},
+			})
+		}
+		return ret
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		reset()
+		p := frame2.Phase{
+			Runner: &frame2.Run{T: t},
+			MainSteps: []frame2.Step{
+				{
+					Parallel:      true,
+					ParallelLimit: 2,
+					Substeps:      substeps(nil, nil, nil, nil, nil),
+				},
+			},
+		}
+		assert.Assert(t, p.Run())
+		assert.Equal(t, started, int32(5))
+		assert.Equal(t, maxRunning, int32(2))
+	})
+
+	t.Run("errors-joined", func(t *testing.T) {
+		reset()
+		err1 := errors.New("first")
+		err2 := errors.New("second")
+		// No testing.T, so the failures do not fail this test
+		p := frame2.Phase{
+			Runner: &frame2.Run{},
+			MainSteps: []frame2.Step{
+				{
+					Parallel: true,
+					Substeps: substeps(err1, nil, err2),
+				},
+			},
+		}
+		err := p.Run()
+		assert.Assert(t, errors.Is(err, err1), err)
+		assert.Assert(t, errors.Is(err, err2), err)
+		assert.Equal(t, started, int32(3))
+		assert.Equal(t, maxRunning, int32(3))
+	})
+
+	t.Run("fail-fast", func(t *testing.T) {
+		reset()
+		p := frame2.Phase{
+			Runner: &frame2.Run{},
+			MainSteps: []frame2.Step{
+				{
+					Parallel:         true,
+					ParallelFailFast: true,
+					ParallelLimit:    1,
+					Substeps:         substeps(errors.New("fail"), nil, nil),
+				},
+			},
+		}
+		assert.Assert(t, p.Run() != nil)
+		assert.Equal(t, started, int32(1))
+	})
+
+	t.Run("setup-teardowns", func(t *testing.T) {
+		reset()
+		// Without a testing.T, the teardown runs at the end of Phase.Run
+		p := frame2.Phase{
+			Runner: &frame2.Run{},
+			Setup: []frame2.Step{
+				{
+					Parallel: true,
+					Substeps: substeps(nil, nil, nil),
+				},
+			},
+		}
+		assert.Assert(t, p.Run())
+		assert.Equal(t, tornDown, int32(3))
+	})
+
+	t.Run("fatal-branch", func(t *testing.T) {
+		reset()
+		setupErr := errors.New("setup")
+		// The nested phase's setup failure would call t.Fatalf; on a
+		// branch, it must become the branch's error instead
+		p := frame2.Phase{
+			Runner: &frame2.Run{T: t},
+			MainSteps: []frame2.Step{
+				{
+					Parallel: true,
+					Substeps: append(substeps(nil), &frame2.Step{
+						Modify: frame2.Phase{
+							Setup: []frame2.Step{
+								{
+									Modify: f2general.Function{
+										Fn: func() error {
+											return setupErr
+										},
+									},
+								},
+							},
+						},
+					}),
+				},
+			},
+		}
+		err := p.Run()
+		assert.Assert(t, errors.Is(err, setupErr), err)
+		assert.Equal(t, started, int32(1))
+	})
+}
+
+// Records the Value it had on each run; used by TestFinalValidatorSnapshot
+type snapshotValidator struct {
+	Value string
+	seen  *[]string
+
+	frame2.Log
+}
+
+func (s *snapshotValidator) Validate() error {
+	*s.seen = append(*s.seen, s.Value)
+	return nil
+}
+
+// Changes the Value of snapshotValidators, on the first step it inspects
+type snapshotChanger struct {
+	done bool
+}
+
+func (s *snapshotChanger) DisruptorEnvValue() string {
+	return "SNAPSHOT_CHANGER"
+}
+
+func (s *snapshotChanger) Inspect(step *frame2.Step, phase *frame2.Phase) {
+	if s.done {
+		return
+	}
+	s.done = true
+	for _, v := range step.GetValidators() {
+		if v, ok := v.(*snapshotValidator); ok {
+			v.Value = "changed"
+		}
+	}
+}
+
+func TestFinalValidatorSnapshot(t *testing.T) {
+	var seen []string
+	runner := &frame2.Run{
+		RequiredDisruptors: []frame2.Disruptor{&snapshotChanger{}},
+	}
+	p := frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			{
+				Validator:      &snapshotValidator{Value: "original", seen: &seen},
+				ValidatorFinal: true,
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+	runner.Finalize()
+
+	// The final re-run sees the validator as it was declared, and not as
+	// changed by the disruptor
+	assert.DeepEqual(t, seen, []string{"changed", "original"})
+}
+
+// Records its Values on each run, like snapshotValidator; as they are on a
+// slice, it needs to copy them on Clone for the snapshot to keep them
+type sliceValidator struct {
+	Values []string
+	seen   *[]string
+
+	frame2.Log
+}
+
+func (s *sliceValidator) Validate() error {
+	*s.seen = append(*s.seen, strings.Join(s.Values, ","))
+	return nil
+}
+
+func (s *sliceValidator) Clone() frame2.Validator {
+	clone := *s
+	clone.Values = append([]string{}, s.Values...)
+	return &clone
+}
+
+// Changes the Values of sliceValidators in place, on the first step it
+// inspects
+type sliceChanger struct {
+	done bool
+}
+
+func (s *sliceChanger) DisruptorEnvValue() string {
+	return "SLICE_CHANGER"
+}
+
+func (s *sliceChanger) Inspect(step *frame2.Step, phase *frame2.Phase) {
+	if s.done {
+		return
+	}
+	s.done = true
+	for _, v := range step.GetValidators() {
+		if v, ok := v.(*sliceValidator); ok {
+			v.Values[0] = "changed"
+		}
+	}
+}
+
+func TestFinalValidatorClone(t *testing.T) {
+	var seen []string
+	runner := &frame2.Run{
+		RequiredDisruptors: []frame2.Disruptor{&sliceChanger{}},
+	}
+	p := frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			{
+				Validator:      &sliceValidator{Values: []string{"original", "kept"}, seen: &seen},
+				ValidatorFinal: true,
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+	runner.Finalize()
+
+	assert.DeepEqual(t, seen, []string{"changed,kept", "original,kept"})
+}
+
+// Invalid settings fail the phase before any of its steps run
+func TestInvalidSettings(t *testing.T) {
+	t.Setenv(frame2.ENV_FINAL_RETRY, "three")
+	var ran bool
+	phase := frame2.Phase{
+		MainSteps: []frame2.Step{
+			{
+				Modify: &frame2.Procedure{
+					Fn: func() { ran = true },
+				},
+			},
+		},
+	}
+	err := phase.Run()
+	assert.ErrorContains(t, err, `invalid int value "three" for SKUPPER_TEST_FINAL_RETRY`)
+	assert.Assert(t, !ran)
+}
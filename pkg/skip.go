@@ -0,0 +1,38 @@
+package frame2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned by an Executor or Validator when what it would do or check does
+// not apply, such as a check for a feature that the Skupper version under
+// test does not have.  Unlike a nil error, it is not counted as a success.
+//
+// A step whose Modify returns it is skipped: its substeps and validators
+// are not run.  A validator that returns it is ignored; if all validators
+// of a step do, the step is skipped.  Skipped named steps call t.Skip;
+// others are only logged.  Either way, a skip does not fail the phase, and
+// the reports list it apart from successes and failures (see
+// ReportNode.Skipped)
+type ErrSkip struct {
+	Reason string
+}
+
+func (e *ErrSkip) Error() string {
+	return "skipped: " + e.Reason
+}
+
+// Returns an *ErrSkip with the formatted reason
+func Skip(format string, a ...any) error {
+	return &ErrSkip{Reason: fmt.Sprintf(format, a...)}
+}
+
+// Returns the ErrSkip on err's chain, if any
+func AsSkip(err error) (*ErrSkip, bool) {
+	var skip *ErrSkip
+	if errors.As(err, &skip) {
+		return skip, true
+	}
+	return nil, false
+}
@@ -0,0 +1,85 @@
+package frame2_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"gotest.tools/assert"
+)
+
+// Returns an ErrSkip when executed or validated
+type skipper struct {
+	reason string
+	frame2.Log
+}
+
+func (s skipper) Execute() error {
+	return frame2.Skip("%s", s.reason)
+}
+
+func (s skipper) Validate() error {
+	return s.Execute()
+}
+
+// Counts its executions
+type counter struct {
+	count *int
+	frame2.Log
+}
+
+func (c counter) Execute() error {
+	*c.count++
+	return nil
+}
+
+func (c counter) Validate() error {
+	return c.Execute()
+}
+
+func TestSkip(t *testing.T) {
+	var notRun, run int
+	runner := &frame2.Run{T: t}
+	p := frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			{
+				Name:      "skipped-named",
+				Modify:    &skipper{reason: "not here"},
+				Validator: &counter{count: &notRun},
+			}, {
+				Doc:       "A skipped Modify skips the rest of the step",
+				Modify:    &skipper{reason: "not now"},
+				Validator: &counter{count: &notRun},
+				Substep: &frame2.Step{
+					Modify: &counter{count: &notRun},
+				},
+			}, {
+				Doc:        "All validators skipped",
+				Validators: []frame2.Validator{&skipper{reason: "no"}, &skipper{reason: "nope"}},
+			}, {
+				Doc:        "Some validators skipped",
+				Validators: []frame2.Validator{&skipper{reason: "no"}, &counter{count: &run}},
+			}, {
+				Modify: &counter{count: &run},
+			},
+		},
+	}
+	assert.Assert(t, p.Run())
+	assert.Equal(t, notRun, 0)
+	assert.Equal(t, run, 2)
+	assert.Equal(t, runner.ReportTree().StepCounts(), frame2.StepCounts{Passed: 2, Skipped: 3})
+
+	var buf bytes.Buffer
+	assert.Assert(t, runner.WriteJUnit(&buf))
+	assert.Assert(t, strings.Contains(buf.String(), `<skipped message="not here"></skipped>`), buf.String())
+	assert.Assert(t, strings.Contains(buf.String(), `failures="0" skipped="`), buf.String())
+	assert.Assert(t, !strings.Contains(buf.String(), "<failure"), buf.String())
+
+	err := frame2.Skip("version %s", "1.4")
+	skip, ok := frame2.AsSkip(err)
+	assert.Assert(t, ok)
+	assert.Equal(t, skip.Reason, "version 1.4")
+	assert.Equal(t, err.Error(), "skipped: version 1.4")
+}
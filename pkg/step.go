@@ -2,9 +2,9 @@ package frame2
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"reflect"
 	"strings"
+	"time"
 )
 
 const EnvFrame2Verbose = "SKUPPER_TEST_FRAME2_VERBOSE"
@@ -33,6 +33,17 @@ type Step struct {
 	Substep           Stepper
 	Substeps          []*Step
 	SubstepRetry      RetryOptions
+	// If set, the substeps run concurrently, each on its own ParallelRunner,
+	// and their errors are joined.  SubstepRetry applies to each of them
+	// individually.  TearDowners on the Modify of parallel substeps of Setup
+	// steps get their auto-teardown installed, like top-level Setup steps
+	Parallel bool
+	// For Parallel substeps: once a substep fails, do not start any others.
+	// Substeps already running are not interrupted; they're waited for
+	ParallelFailFast bool
+	// For Parallel substeps: how many can run at the same time.  Unlimited
+	// if zero
+	ParallelLimit int
 	// A simple way to invert the meaning of the Validator.  Validators
 	// are encouraged to provide more specific negative testing behaviors,
 	// but this serves for simpler testing.  If set, it inverts the
@@ -45,21 +56,35 @@ type Step struct {
 	ExpectError bool
 	// TODO: ExpectIs, ExpectAs; use errors.Is, errors.As against a list of expected errors?
 	SkipWhen bool
+
+	// If set, the step (its Modify, substeps and validators, with their
+	// retries) must finish within this time.  The step's runner gets a
+	// context with this timeout, which is given to the frames that have
+	// no context of their own (including the Ctx of RunDealer frames).
+	//
+	// Frames that ignore their context are abandoned on their own
+	// goroutine when the timeout expires, and the step fails with a
+	// TimeoutError naming its runner
+	Timeout time.Duration
 }
 
 func (s *Step) GetStep() *Step {
 	return s
 }
 
+// Logs at LevelInfo if the step is Verbose, and at LevelDebug otherwise
+// (see Logf).  The message is indented by the step's Level
 func (s Step) Logf(format string, v ...interface{}) {
-	if s.IsVerbose() {
-		left := strings.Repeat(" ", s.Level)
-		log.Printf(left+format, v...)
+	level := LevelDebug
+	if s.Verbose {
+		level = LevelInfo
 	}
+	left := strings.Repeat(" ", s.Level)
+	logAt(nil, nil, level, 1, left+format, v)
 }
 
 func (s Step) IsVerbose() bool {
-	return s.Verbose || os.Getenv(EnvFrame2Verbose) != ""
+	return s.Verbose || settingVerbose.Enabled()
 }
 
 // Returns a list where Step.Validator is the first item, followed by
@@ -76,6 +101,20 @@ func (s Step) GetValidators() []Validator {
 	return validators
 }
 
+// Returns a list where Step.Substep is the first item, followed by
+// Step.Substeps
+func (s Step) GetSubsteps() []*Step {
+	substeps := []*Step{}
+
+	if s.Substep != nil {
+		substeps = append(substeps, s.Substep.GetStep())
+	}
+
+	substeps = append(substeps, s.Substeps...)
+
+	return substeps
+}
+
 type TransformFunc func(any) (any, error)
 
 // IterFrames will run transform() on each of its configured frames
@@ -153,6 +192,49 @@ type Validator interface {
 type Execute struct {
 }
 
+// Validators that implement ValidatorCloner provide their own copies for
+// the re-runs of validators marked as final or sub-final.  Otherwise, a
+// shallow copy of the validator's struct is used; see snapshotValidators.
+//
+// Final and sub-final validators whose configuration is held on pointer,
+// slice or map fields need to implement it, copying that configuration,
+// for the re-runs to be protected from changes done on it by disruptors
+type ValidatorCloner interface {
+	Clone() Validator
+}
+
+// Returns copies of the validators as they are now, so that later changes
+// on the originals (such as those done by disruptors on Inspect) do not
+// affect the copies.
+//
+// Validators that implement ValidatorCloner are copied with Clone().  For
+// pointers to structs, the copy is a new struct with the same field values.
+// That is a one-level copy: pointers, slices and maps on the fields are
+// still shared with the original, so result holders filled by the
+// validator keep working, but changes on the values they point to are
+// seen by the copy as well; validators that need those protected must
+// implement ValidatorCloner.  Other validators are used as they are
+func snapshotValidators(validators []Validator) []Validator {
+	ret := make([]Validator, 0, len(validators))
+	for _, v := range validators {
+		if cloner, ok := v.(ValidatorCloner); ok {
+			ret = append(ret, cloner.Clone())
+			continue
+		}
+		value := reflect.ValueOf(v)
+		if value.Kind() == reflect.Pointer && !value.IsNil() && value.Elem().Kind() == reflect.Struct {
+			snapshot := reflect.New(value.Elem().Type())
+			snapshot.Elem().Set(value.Elem())
+			if snapshot, ok := snapshot.Interface().(Validator); ok {
+				ret = append(ret, snapshot)
+				continue
+			}
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
 type Executor interface {
 	Execute() error
 }
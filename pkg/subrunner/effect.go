@@ -4,8 +4,14 @@ import (
 	"context"
 	"fmt"
 	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/env"
 	"github.com/imdario/mergo"
 )
 
@@ -15,9 +21,26 @@ const (
 	COMBO ExecutionProfile = iota
 	INDIVIDUAL
 	BOTH
-	// RANDOM: random apply
+	// RandomCount combos, each with a random subset of the Effects; see
+	// RandomSeed
+	RANDOM
+	// Generated combos that cover every pair of Effects: each two of them
+	// are applied together on some combo, and each is applied without the
+	// other on another (see Matrix.Pairwise)
+	PAIRWISE
 )
 
+// If set, the seed for the RANDOM profile, when Effects.RandomSeed is
+// zero.  The seed used is always logged, so a run can be reproduced
+const ENV_SUBRUNNER_SEED = "SKUPPER_TEST_SUBRUNNER_SEED"
+
+var settingSeed = env.Register(env.Setting{
+	Name:        ENV_SUBRUNNER_SEED,
+	Type:        env.Int,
+	Description: "the seed for the RANDOM profile of subrunner.Effects; a new one is used if not set",
+	Package:     "subrunner",
+})
+
 // On each cycle executed by Effects, its BaseFrame is patched
 // with the Patch below, and the Validators ensue
 type CauseEffect[T frame2.Executor] struct {
@@ -26,6 +49,11 @@ type CauseEffect[T frame2.Executor] struct {
 	Validators      []frame2.Validator
 	FailValidators  []frame2.Validator
 	ValidatorsRetry frame2.RetryOptions
+
+	// If set, the effect is not used on the combos generated by the RANDOM
+	// and PAIRWISE profiles; for example, if its validations would
+	// conflict with those of other effects
+	Standalone bool
 }
 
 // A generic cause-effect verifier
@@ -53,6 +81,13 @@ type Effects[T frame2.Executor, PT interface {
 	Combos map[string][]string
 
 	ExecutionProfile ExecutionProfile
+
+	// For the RANDOM profile: the number of combos; if zero, the number
+	// of Effects that are not Standalone
+	RandomCount int
+	// For the RANDOM profile: if zero, ENV_SUBRUNNER_SEED is used or, if
+	// not set, a new seed
+	RandomSeed int64
 }
 
 // Stepper
@@ -66,19 +101,123 @@ func (e *Effects[T, PT]) GetStep() (*frame2.Step, context.CancelFunc) {
 		s = e.getIndividualStep()
 	case BOTH:
 		s, cancel = e.getBothStep()
+	case RANDOM:
+		s, cancel = e.buildComboStep(e.randomCombos())
+	case PAIRWISE:
+		s, cancel = e.buildComboStep(e.pairwiseCombos())
 	default:
 		panic(fmt.Sprintf("no such ExecutionProfile: %v", e.ExecutionProfile))
 	}
 	return s, cancel
 }
 
+// A combo to be run, with its name
+type namedCombo struct {
+	name    string
+	effects []string
+}
+
+// The user-listed Combos, by name
 func (e Effects[T, PT]) getComboStep() (*frame2.Step, context.CancelFunc) {
-	var cancel context.CancelFunc
+	var combos []namedCombo
+	for name, effects := range e.Combos {
+		combos = append(combos, namedCombo{name: name, effects: effects})
+	}
+	sort.Slice(combos, func(i, j int) bool {
+		return combos[i].name < combos[j].name
+	})
+	return e.buildComboStep(combos)
+}
+
+// The names of the effects that can be used on generated combos, sorted
+func (e Effects[T, PT]) comboEffects() []string {
+	var names []string
+	for name, effect := range e.Effects {
+		if !effect.Standalone {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generated combos are named after their effects
+func newNamedCombo(effects []string) namedCombo {
+	return namedCombo{name: strings.Join(effects, "+"), effects: effects}
+}
+
+// RandomCount combos, each with a random, non-empty subset of the
+// effects.  Repeated subsets are drawn again, up to a limit, so fewer
+// combos may be returned if there are not enough effects
+func (e Effects[T, PT]) randomCombos() []namedCombo {
+	seed := e.RandomSeed
+	if seed == 0 {
+		seed = int64(settingSeed.Int())
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	frame2.Logf("Effects %q: RANDOM profile with seed %d (set %s to reproduce)", e.Name, seed, ENV_SUBRUNNER_SEED)
+	rnd := rand.New(rand.NewSource(seed))
+
+	names := e.comboEffects()
+	count := e.RandomCount
+	if count == 0 {
+		count = len(names)
+	}
+	var ret []namedCombo
+	seen := map[string]bool{}
+	for attempts := 0; len(ret) < count && len(names) > 0 && attempts < count*10; attempts++ {
+		var effects []string
+		for _, name := range names {
+			if rnd.Intn(2) == 1 {
+				effects = append(effects, name)
+			}
+		}
+		combo := newNamedCombo(effects)
+		if len(effects) == 0 || seen[combo.name] {
+			continue
+		}
+		seen[combo.name] = true
+		ret = append(ret, combo)
+	}
+	return ret
+}
+
+// Combos that cover all pairs of effects, each of which can be on or off;
+// the combo with no effects is not used
+func (e Effects[T, PT]) pairwiseCombos() []namedCombo {
+	names := e.comboEffects()
+	sizes := make([]int, len(names))
+	for i := range sizes {
+		sizes[i] = 2
+	}
+	notEmpty := func(c []int) bool {
+		return slices.Contains(c, 1)
+	}
+	var ret []namedCombo
+	for _, c := range pairwise(cartesian(sizes, notEmpty)) {
+		var effects []string
+		for i, on := range c {
+			if on == 1 {
+				effects = append(effects, names[i])
+			}
+		}
+		ret = append(ret, newNamedCombo(effects))
+	}
+	return ret
+}
+
+// Returns a step with a named substep for each combo, where the BaseFrame
+// is patched with all of the combo's effects
+func (e Effects[T, PT]) buildComboStep(combos []namedCombo) (*frame2.Step, context.CancelFunc) {
+	var cancels []context.CancelFunc
 	s := frame2.Step{
 		Name: e.Name,
 		Doc:  e.Doc,
 	}
-	for name, effects := range e.Combos {
+	for _, combo := range combos {
+		name, effects := combo.name, combo.effects
 		frame := *e.BaseFrame
 		validators := []frame2.Validator{}
 		failValidators := []frame2.Validator{}
@@ -90,7 +229,11 @@ func (e Effects[T, PT]) getComboStep() (*frame2.Step, context.CancelFunc) {
 			}
 			validators = append(validators, e.Effects[effect].Validators...)
 			failValidators = append(failValidators, e.Effects[effect].FailValidators...)
+			var cancel context.CancelFunc
 			opt, cancel = opt.Max(e.Effects[effect].ValidatorsRetry)
+			if cancel != nil {
+				cancels = append(cancels, cancel)
+			}
 		}
 		sub := frame2.Step{
 			Name: name,
@@ -113,7 +256,14 @@ func (e Effects[T, PT]) getComboStep() (*frame2.Step, context.CancelFunc) {
 		}
 		s.Substeps = append(s.Substeps, &sub)
 	}
-	return &s, cancel
+	if len(cancels) == 0 {
+		return &s, nil
+	}
+	return &s, func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}
 }
 
 func (e Effects[T, PT]) getIndividualStep() *frame2.Step {
@@ -0,0 +1,134 @@
+package subrunner_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/subrunner"
+	"gotest.tools/assert"
+)
+
+// Records the flags it was executed with
+type flagFrame struct {
+	Console, Flow, Auth, Policies, Ingress bool
+
+	runs *[]string
+}
+
+func (f flagFrame) Execute() error {
+	var flags []string
+	for name, set := range map[string]bool{
+		"auth":     f.Auth,
+		"console":  f.Console,
+		"flow":     f.Flow,
+		"ingress":  f.Ingress,
+		"policies": f.Policies,
+	} {
+		if set {
+			flags = append(flags, name)
+		}
+	}
+	sort.Strings(flags)
+	*f.runs = append(*f.runs, strings.Join(flags, "+"))
+	return nil
+}
+
+func flagEffects(profile subrunner.ExecutionProfile, runs *[]string) subrunner.Effects[flagFrame, *flagFrame] {
+	return subrunner.Effects[flagFrame, *flagFrame]{
+		Name:             "effects",
+		ExecutionProfile: profile,
+		BaseFrame:        &flagFrame{runs: runs},
+		Effects: map[string]subrunner.CauseEffect[flagFrame]{
+			"auth":     {Patch: flagFrame{Auth: true}},
+			"console":  {Patch: flagFrame{Console: true}},
+			"flow":     {Patch: flagFrame{Flow: true}},
+			"ingress":  {Patch: flagFrame{Ingress: true}},
+			"policies": {Patch: flagFrame{Policies: true}},
+			"defaults": {Patch: flagFrame{}, Standalone: true},
+		},
+	}
+}
+
+func TestEffectsPairwise(t *testing.T) {
+	var runs []string
+	e := flagEffects(subrunner.PAIRWISE, &runs)
+	step, cancel := e.GetStep()
+	if cancel != nil {
+		defer cancel()
+	}
+	assert.Assert(t, len(step.Substeps) < 31, "expected fewer combos than the 31 non-empty subsets; got %d", len(step.Substeps))
+
+	p := frame2.Phase{
+		Runner:    &frame2.Run{T: t},
+		MainSteps: []frame2.Step{*step},
+	}
+	assert.Assert(t, p.Run())
+	assert.Equal(t, len(runs), len(step.Substeps))
+	for i, sub := range step.Substeps {
+		// The combos are named after their effects, and the frame got
+		// exactly those
+		assert.Equal(t, sub.Name, runs[i])
+	}
+
+	// Each two effects are applied together, and each without the other
+	effects := []string{"auth", "console", "flow", "ingress", "policies"}
+	has := func(run, effect string) bool {
+		for _, e := range strings.Split(run, "+") {
+			if e == effect {
+				return true
+			}
+		}
+		return false
+	}
+	for _, a := range effects {
+		for _, b := range effects {
+			if a == b {
+				continue
+			}
+			var together, apart bool
+			for _, run := range runs {
+				together = together || (has(run, a) && has(run, b))
+				apart = apart || (has(run, a) && !has(run, b))
+			}
+			assert.Assert(t, together, "%s and %s never together on %v", a, b, runs)
+			assert.Assert(t, apart, "%s never without %s on %v", a, b, runs)
+		}
+	}
+}
+
+func TestEffectsRandom(t *testing.T) {
+	var first, second []string
+	for _, runs := range []*[]string{&first, &second} {
+		e := flagEffects(subrunner.RANDOM, runs)
+		e.RandomSeed = 42
+		e.RandomCount = 4
+		p := e.GetPhase(&frame2.Run{T: t})
+		assert.Assert(t, p.Run())
+	}
+	assert.Equal(t, len(first), 4)
+	// The same seed gives the same combos
+	assert.DeepEqual(t, first, second)
+	for _, run := range first {
+		assert.Assert(t, run != "", "combos should not be empty")
+	}
+
+	// The seed can also come from the environment
+	var fromEnv []string
+	t.Setenv(subrunner.ENV_SUBRUNNER_SEED, "42")
+	e := flagEffects(subrunner.RANDOM, &fromEnv)
+	e.RandomCount = 4
+	p := e.GetPhase(&frame2.Run{T: t})
+	assert.Assert(t, p.Run())
+	assert.DeepEqual(t, fromEnv, first)
+
+	// By default, one combo per effect; Standalone ones do not count
+	e = flagEffects(subrunner.RANDOM, &fromEnv)
+	e.RandomSeed = 42
+	step, cancel := e.GetStep()
+	if cancel != nil {
+		defer cancel()
+	}
+	assert.Equal(t, len(step.Substeps), 5)
+}
@@ -0,0 +1,213 @@
+package subrunner
+
+import (
+	"fmt"
+	"strings"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+)
+
+// A parameter of a Matrix, and the values it takes.  Both are used on the
+// names of the generated subtests, so they should be short
+type Parameter struct {
+	Name   string
+	Values []string
+}
+
+// A value for each parameter of a Matrix, by parameter name
+type Combination map[string]string
+
+// Generates a named subtest for each combination of the values of its
+// Parameters, such as router mode × console on/off × token type, with the
+// steps built by Steps for that combination.
+//
+// The subtests are named after their values, in the order of the
+// Parameters, as in "mode=edge,console=off,token=link", and they are
+// generated in order: the last parameter changes first.  The names and
+// the order do not change between runs.
+//
+// Matrix is a frame2.Stepper, so it can be used directly as a Substep
+type Matrix struct {
+	Name       string
+	Doc        string
+	Parameters []Parameter
+
+	// Combinations for which any of these returns true are not generated;
+	// for example, for features that a router mode does not support
+	Exclude []func(Combination) bool
+
+	// If set, instead of every combination, only enough of them to cover
+	// each pair of values of any two parameters (all-pairs testing) are
+	// generated.  Pairs that only appear in excluded combinations are not
+	// covered.
+	//
+	// The reduction is greedy, and picks from the full list of valid
+	// combinations, so that list needs to fit in memory
+	Pairwise bool
+
+	// Returns the steps for a combination; they run as the substeps of
+	// the combination's subtest
+	Steps func(Combination) []frame2.Step
+}
+
+// Returns the combinations to be run, in order, after the exclusions and
+// the pairwise reduction, if requested
+func (m Matrix) Combinations() []Combination {
+	sizes := make([]int, len(m.Parameters))
+	for i, p := range m.Parameters {
+		sizes[i] = len(p.Values)
+	}
+	valid := func(indexes []int) bool {
+		c := m.combination(indexes)
+		for _, exclude := range m.Exclude {
+			if exclude(c) {
+				return false
+			}
+		}
+		return true
+	}
+	all := cartesian(sizes, valid)
+	if m.Pairwise {
+		all = pairwise(all)
+	}
+	ret := make([]Combination, len(all))
+	for i, indexes := range all {
+		ret[i] = m.combination(indexes)
+	}
+	return ret
+}
+
+func (m Matrix) combination(indexes []int) Combination {
+	c := make(Combination, len(indexes))
+	for i, v := range indexes {
+		c[m.Parameters[i].Name] = m.Parameters[i].Values[v]
+	}
+	return c
+}
+
+// The name of the combination's subtest
+func (m Matrix) CombinationName(c Combination) string {
+	parts := make([]string, len(m.Parameters))
+	for i, p := range m.Parameters {
+		parts[i] = fmt.Sprintf("%s=%s", p.Name, c[p.Name])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Stepper
+func (m Matrix) GetStep() *frame2.Step {
+	s := frame2.Step{
+		Name: m.Name,
+		Doc:  m.Doc,
+	}
+	for _, c := range m.Combinations() {
+		name := m.CombinationName(c)
+		sub := frame2.Step{
+			Name: name,
+			Doc:  strings.TrimSpace(fmt.Sprintf("%s (%s)", m.Doc, name)),
+		}
+		for _, step := range m.Steps(c) {
+			sub.Substeps = append(sub.Substeps, &step)
+		}
+		s.Substeps = append(s.Substeps, &sub)
+	}
+	return &s
+}
+
+func (m Matrix) GetPhase(runner *frame2.Run) frame2.Phase {
+	return frame2.Phase{
+		Runner: runner,
+		MainSteps: []frame2.Step{
+			*m.GetStep(),
+		},
+	}
+}
+
+// Returns all combinations of indexes for lists of the given sizes for
+// which valid returns true, with the last index changing first
+func cartesian(sizes []int, valid func([]int) bool) [][]int {
+	for _, size := range sizes {
+		if size == 0 {
+			return nil
+		}
+	}
+	var ret [][]int
+	current := make([]int, len(sizes))
+	for {
+		if valid(current) {
+			ret = append(ret, append([]int{}, current...))
+		}
+		i := len(sizes) - 1
+		for ; i >= 0; i-- {
+			current[i]++
+			if current[i] < sizes[i] {
+				break
+			}
+			current[i] = 0
+		}
+		if i < 0 {
+			return ret
+		}
+	}
+}
+
+// A value for each of two positions of a combination of indexes
+type pair struct {
+	i, vi, j, vj int
+}
+
+func pairsOf(c []int) []pair {
+	var ret []pair
+	for i := 0; i < len(c); i++ {
+		for j := i + 1; j < len(c); j++ {
+			ret = append(ret, pair{i, c[i], j, c[j]})
+		}
+	}
+	return ret
+}
+
+// Reduces the candidates to a subset that covers every pair of values
+// that they cover.  On each round, the candidate that covers most of the
+// pairs not covered yet is picked; ties go to the first one, so the
+// result is deterministic.  The picked candidates are returned in their
+// original order
+func pairwise(candidates [][]int) [][]int {
+	if len(candidates) == 0 || len(candidates[0]) < 2 {
+		return candidates
+	}
+	uncovered := map[pair]bool{}
+	for _, c := range candidates {
+		for _, p := range pairsOf(c) {
+			uncovered[p] = true
+		}
+	}
+	picked := make([]bool, len(candidates))
+	for len(uncovered) > 0 {
+		best, bestCount := -1, 0
+		for i, c := range candidates {
+			if picked[i] {
+				continue
+			}
+			count := 0
+			for _, p := range pairsOf(c) {
+				if uncovered[p] {
+					count++
+				}
+			}
+			if count > bestCount {
+				best, bestCount = i, count
+			}
+		}
+		picked[best] = true
+		for _, p := range pairsOf(candidates[best]) {
+			delete(uncovered, p)
+		}
+	}
+	var ret [][]int
+	for i, c := range candidates {
+		if picked[i] {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
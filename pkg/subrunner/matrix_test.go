@@ -0,0 +1,100 @@
+package subrunner_test
+
+import (
+	"fmt"
+	"testing"
+
+	frame2 "github.com/hash-d/frame2/pkg"
+	"github.com/hash-d/frame2/pkg/frames/f2general"
+	"github.com/hash-d/frame2/pkg/subrunner"
+	"gotest.tools/assert"
+)
+
+var testParameters = []subrunner.Parameter{
+	{Name: "mode", Values: []string{"interior", "edge"}},
+	{Name: "console", Values: []string{"on", "off"}},
+	{Name: "token", Values: []string{"link", "claim", "grant"}},
+}
+
+func TestMatrix(t *testing.T) {
+	var ran []string
+	m := subrunner.Matrix{
+		Name:       "matrix",
+		Parameters: testParameters,
+		Exclude: []func(subrunner.Combination) bool{
+			func(c subrunner.Combination) bool {
+				return c["mode"] == "edge" && c["token"] == "grant"
+			},
+		},
+		Steps: func(c subrunner.Combination) []frame2.Step {
+			name := fmt.Sprintf("%s/%s/%s", c["mode"], c["console"], c["token"])
+			return []frame2.Step{
+				{
+					Modify: &f2general.Function{
+						Fn: func() error {
+							ran = append(ran, name)
+							return nil
+						},
+					},
+				},
+			}
+		},
+	}
+
+	step := m.GetStep()
+	assert.Equal(t, len(step.Substeps), 10)
+	assert.Equal(t, step.Substeps[0].Name, "mode=interior,console=on,token=link")
+	assert.Equal(t, step.Substeps[9].Name, "mode=edge,console=off,token=claim")
+
+	p := m.GetPhase(&frame2.Run{T: t})
+	assert.Assert(t, p.Run())
+	assert.DeepEqual(t, ran, []string{
+		"interior/on/link", "interior/on/claim", "interior/on/grant",
+		"interior/off/link", "interior/off/claim", "interior/off/grant",
+		"edge/on/link", "edge/on/claim",
+		"edge/off/link", "edge/off/claim",
+	})
+}
+
+func TestMatrixPairwise(t *testing.T) {
+	params := []subrunner.Parameter{}
+	for i := 0; i < 5; i++ {
+		params = append(params, subrunner.Parameter{
+			Name:   fmt.Sprintf("p%d", i),
+			Values: []string{"a", "b", "c"},
+		})
+	}
+	exclude := func(c subrunner.Combination) bool {
+		return c["p0"] == "a" && c["p1"] == "b"
+	}
+	full := subrunner.Matrix{Parameters: params, Exclude: []func(subrunner.Combination) bool{exclude}}
+	reduced := full
+	reduced.Pairwise = true
+
+	all := full.Combinations()
+	combos := reduced.Combinations()
+	assert.Equal(t, len(all), 3*3*3*3*3-3*3*3)
+	assert.Assert(t, len(combos) < 20, "expected a reduction from 243 combinations; got %d", len(combos))
+
+	// Every pair present on the valid combinations is covered
+	covered := func(list []subrunner.Combination) map[string]bool {
+		ret := map[string]bool{}
+		for _, c := range list {
+			for i := range params {
+				for j := i + 1; j < len(params); j++ {
+					a, b := params[i].Name, params[j].Name
+					ret[fmt.Sprintf("%s=%s,%s=%s", a, c[a], b, c[b])] = true
+				}
+			}
+		}
+		return ret
+	}
+	assert.DeepEqual(t, covered(combos), covered(all))
+	assert.Assert(t, !covered(combos)["p0=a,p1=b"])
+	for _, c := range combos {
+		assert.Assert(t, !exclude(c))
+	}
+
+	// The reduction is deterministic
+	assert.DeepEqual(t, reduced.Combinations(), combos)
+}